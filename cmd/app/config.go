@@ -0,0 +1,34 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"github.com/ledorub/snote-api/internal/config"
+)
+
+const configUsage = "usage: snote [flags] config check [FILE]"
+
+// runConfig executes the config subcommand with the arguments following it.
+// check validates the loaded config or, if FILE is given, the one loaded from FILE and env.
+func runConfig(cfg *config.Config, args []string) error {
+	if len(args) == 0 || args[0] != "check" || len(args) > 2 {
+		return errors.New(configUsage)
+	}
+
+	if len(args) == 2 {
+		loader := config.NewLoader(config.LoadEnv(), config.LoadFile(args[1], nil))
+		var err error
+		if cfg, err = loader.Load(); err != nil {
+			return err
+		}
+	}
+	name := "config"
+	if cfg.Source.File != "" {
+		name = fmt.Sprintf("config %s", cfg.Source.File)
+	}
+	if err := cfg.Validate(); err != nil {
+		return fmt.Errorf("invalid %s:\n%w", name, err)
+	}
+	fmt.Printf("%s is valid\n", name)
+	return nil
+}
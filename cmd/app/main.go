@@ -2,110 +2,407 @@ package main
 
 import (
 	"context"
+	"crypto/rand"
+	"database/sql"
 	"errors"
+	"flag"
 	"fmt"
 	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/ledorub/snote-api/internal"
 	"github.com/ledorub/snote-api/internal/api/common"
 	"github.com/ledorub/snote-api/internal/api/request"
+	"github.com/ledorub/snote-api/internal/api/resource/health"
 	"github.com/ledorub/snote-api/internal/api/resource/note"
 	"github.com/ledorub/snote-api/internal/api/response"
 	"github.com/ledorub/snote-api/internal/api/router"
 	"github.com/ledorub/snote-api/internal/config"
 	"github.com/ledorub/snote-api/internal/db"
+	"github.com/ledorub/snote-api/internal/db/memory"
+	"github.com/ledorub/snote-api/internal/db/sqlite"
 	"github.com/ledorub/snote-api/internal/encdec"
 	"github.com/ledorub/snote-api/internal/logger"
+	"github.com/ledorub/snote-api/internal/metrics"
 	"github.com/ledorub/snote-api/internal/service"
+	"github.com/ledorub/snote-api/internal/tracing"
 	"github.com/ledorub/snote-api/internal/validator"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/trace"
+	"go.opentelemetry.io/otel/trace/noop"
 	"log"
+	"log/slog"
 	"net/http"
+	"os"
 	"os/signal"
 	"syscall"
 	"time"
 )
 
 func main() {
-	cfg, err := loadConfig()
+	cfgLoader := createConfigLoader()
+	cfg, err := cfgLoader.Load()
 	if err != nil {
 		log.Fatal(err)
 	}
 
-	lg := createLogger()
-	lg.Println("Set up logger.")
-
 	cfgPretty, err := cfg.Pretty()
 	if err != nil {
-		log.Fatalf("unable to print config: %w", err)
+		log.Fatalf("unable to print config: %v", err)
+	}
+	if cfg.PrintConfig {
+		fmt.Print(cfgPretty)
+		return
+	}
+
+	args := flag.Args()
+	if len(args) > 0 && args[0] == "config" {
+		if err = runConfig(cfg, args[1:]); err != nil {
+			log.Fatal(err)
+		}
+		return
+	}
+	if err = cfg.Validate(); err != nil {
+		log.Fatalf("invalid config:\n%v", err)
+	}
+
+	logLevel := &slog.LevelVar{}
+	lg, err := createLogger(&cfg.Log, logLevel)
+	if err != nil {
+		log.Fatal(err)
+	}
+	slog.SetDefault(lg)
+	lg.Info("set up logger")
+	lg.Info("loaded config", "config", cfgPretty)
+
+	if len(args) > 0 {
+		runCommand(lg, cfg, args)
+		return
 	}
-	log.Printf("Config:\n%v", cfgPretty)
 
 	ctx, stop := createMainContext()
 	defer stop()
 
-	dbConn, err := createDBConnection(ctx, &cfg.DB)
+	tracerProvider, shutdownTracing, err := createTracerProvider(ctx, &cfg.Tracing)
 	if err != nil {
-		lg.Fatal(err)
+		fatal(lg, "tracing setup failed", err)
+	}
+	metricsRegistry := metrics.NewRegistry()
+	noteMetrics := metrics.NewNoteMetrics(metricsRegistry)
+	noteStorage, err := createStorage(ctx, lg, cfg, metricsRegistry, tracerProvider)
+	if err != nil {
+		fatal(lg, "storage setup failed", err)
+	}
+	revealTokens, err := createRevealTokens(lg, &cfg.Reveal)
+	if err != nil {
+		fatal(lg, "reveal tokens setup failed", err)
+	}
+	idEncDec, err := createIDEncDec(&cfg.ID, &cfg.IDFormat)
+	if err != nil {
+		fatal(lg, "ID encoder setup failed", err)
+	}
+	noteService := createNoteService(
+		lg, &cfg.Lockout, &cfg.Note, noteStorage.repo, idEncDec, revealTokens, noteMetrics, tracerProvider,
+	)
+	noteReaper := createNoteReaper(lg, &cfg.Reaper, noteStorage.repo, noteMetrics)
+	reaperDone := startReaper(ctx, noteReaper)
+
+	cfgReloader := newConfigReloader(lg, cfgLoader, cfg, func(cfg *config.Config) {
+		logLevel.Set(cfg.Log.SlogLevel())
+		noteService.SetMaxFailedAttempts(uint(cfg.Lockout.MaxFailedAttempts.Value))
+		noteService.SetNoteLimits(cfg.Note.Limits())
+		revealTokens.SetTTL(cfg.Reveal.TokenTTL.Value)
+		noteReaper.Configure(cfg.Reaper.Interval.Value, cfg.Reaper.BatchSize.Value)
+	})
+	go cfgReloader.Run(ctx)
+
+	jsonResponseWriter := createJSONResponseWriter(lg)
+	healthAPI := health.NewAPI(lg, jsonResponseWriter, noteStorage.check)
+	adminRouter := router.NewAdminRouter(health.NewRouter(healthAPI), metricsRegistry.Handler())
+	var publicAdminRouter http.Handler
+	stopAdminServer := func() {}
+	if cfg.Server.AdminPort.Value == 0 {
+		if cfg.Server.ExposeAdmin.Value {
+			publicAdminRouter = adminRouter
+		} else {
+			lg.Info("admin API: disabled, set an admin port to serve health endpoints and metrics")
+		}
+	} else {
+		adminAPI := router.NewAdmin(lg, jsonResponseWriter, adminRouter)
+		stopAdminServer = startAdminServer(lg, &cfg.Server, adminAPI)
+	}
+
+	httpMetrics := metrics.NewHTTPMetrics(metricsRegistry)
+	api := createAPI(lg, jsonResponseWriter, httpMetrics, tracerProvider, noteService, publicAdminRouter)
+	beforeShutdown := drain(lg, healthAPI, cfg.Server.DrainDelay.Value)
+	if err = startServer(lg, ctx, createServer(&cfg.Server, api), beforeShutdown); err != nil {
+		lg.Error("server: failed", "error", err)
 	}
-	noteRepo := createNoteRepo(lg, dbConn)
-	noteService := createNoteService(lg, noteRepo)
+	// The admin listener outlives the public one, so that probes see the server draining.
+	stopAdminServer()
+	stop()
+	<-reaperDone
+	noteStorage.close()
+	closeTracing(lg, shutdownTracing)
+}
 
-	api := createAPI(lg, noteService)
-	if err = startServer(lg, ctx, &cfg.Server, api); err != nil {
-		lg.Printf("server: %w", err)
+func runCommand(logger *slog.Logger, cfg *config.Config, args []string) {
+	var err error
+	switch args[0] {
+	case "migrate":
+		err = runMigrate(logger, cfg, args[1:])
+	default:
+		err = fmt.Errorf("unknown command %q", args[0])
+	}
+	if err != nil {
+		fatal(logger, "command failed", err)
 	}
-	closeDBConnection(lg, dbConn)
 }
 
-func loadConfig() (*config.Config, error) {
-	cfgLoader := config.NewLoader(config.LoadArgs())
-	return cfgLoader.Load()
+// fatal is the log.Fatal counterpart for slog.
+func fatal(logger *slog.Logger, msg string, err error) {
+	logger.Error(msg, "error", err)
+	os.Exit(1)
+}
+
+func createConfigLoader() *config.Loader {
+	return config.NewLoader(config.LoadArgs(), config.LoadEnv())
+}
+
+func createLogger(logConfig *config.LogConfig, level *slog.LevelVar) (*slog.Logger, error) {
+	level.Set(logConfig.SlogLevel())
+	return logger.New(level, logConfig.Format.Value)
+}
+
+// createTracerProvider returns the provider of the configured exporter along with the func flushing its spans.
+func createTracerProvider(
+	ctx context.Context,
+	tracingConfig *config.TracingConfig,
+) (trace.TracerProvider, func(ctx context.Context) error, error) {
+	var exporter sdktrace.SpanExporter
+	var err error
+	switch tracingConfig.Exporter.Value {
+	case config.TracingExporterOTLP:
+		exporter, err = tracing.NewOTLPExporter(ctx, tracingConfig.Endpoint.Value)
+	case config.TracingExporterStdout:
+		exporter, err = tracing.NewStdoutExporter()
+	default:
+		return noop.NewTracerProvider(), func(ctx context.Context) error { return nil }, nil
+	}
+	if err != nil {
+		return nil, nil, err
+	}
+	provider := tracing.NewProvider(exporter)
+	return provider, provider.Shutdown, nil
 }
 
-func createLogger() *log.Logger {
-	return logger.New()
+func closeTracing(logger *slog.Logger, shutdown func(ctx context.Context) error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if err := shutdown(ctx); err != nil {
+		logger.Error("tracing: span flushing failed", "error", err)
+	}
 }
 
 func createMainContext() (context.Context, context.CancelFunc) {
 	return signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
 }
 
-func createDBConnection(ctx context.Context, dbConfig *config.DBConfig) (*pgxpool.Pool, error) {
-	dsn := db.BuildDSN(
+type noteRepository interface {
+	Create(ctx context.Context, note *internal.NoteModel) (*internal.NoteModel, error)
+	Get(ctx context.Context, id uint64) (*internal.NoteModel, error)
+	View(ctx context.Context, id uint64, keyHash []byte, viewsRemaining uint) (*internal.NoteModel, error)
+	Delete(ctx context.Context, id uint64) error
+	RecordFailedAttempt(ctx context.Context, id uint64) (uint, error)
+	DeleteExpired(ctx context.Context, before time.Time, limit uint64) (uint64, error)
+}
+
+// storage is the note repository of the configured driver along with the means to check and close it.
+type storage struct {
+	repo  noteRepository
+	check func(ctx context.Context) error
+	close func()
+}
+
+func createStorage(
+	ctx context.Context,
+	logger *slog.Logger,
+	cfg *config.Config,
+	metricsRegistry *metrics.Registry,
+	tracerProvider trace.TracerProvider,
+) (*storage, error) {
+	switch cfg.Storage.Driver.Value {
+	case config.StorageDriverMemory:
+		logger.Warn("storage: using in-memory storage, notes will not survive a restart")
+		return &storage{
+			repo:  memory.NewNoteRepository(logger),
+			check: func(ctx context.Context) error { return nil },
+			close: func() {},
+		}, nil
+	case config.StorageDriverSQLite:
+		sqliteConn, err := sqlite.Open(ctx, cfg.Storage.Path.Value)
+		if err != nil {
+			return nil, err
+		}
+		return &storage{
+			repo:  sqlite.NewNoteRepository(logger, sqliteConn),
+			check: sqliteConn.PingContext,
+			close: func() { closeSQLiteConnection(logger, sqliteConn) },
+		}, nil
+	default:
+		dbConn, err := createDBConnection(ctx, &cfg.DB, tracerProvider)
+		if err != nil {
+			return nil, err
+		}
+		if err = autoMigrate(logger, &cfg.DB); err != nil {
+			closeDBConnection(logger, dbConn)
+			return nil, err
+		}
+		db.RegisterPoolMetrics(metricsRegistry, dbConn)
+		return &storage{
+			repo:  createNoteRepo(logger, dbConn),
+			check: func(ctx context.Context) error { return db.CheckConnection(ctx, dbConn) },
+			close: func() { closeDBConnection(logger, dbConn) },
+		}, nil
+	}
+}
+
+func createDBConnection(
+	ctx context.Context,
+	dbConfig *config.DBConfig,
+	tracerProvider trace.TracerProvider,
+) (*pgxpool.Pool, error) {
+	return db.CreatePool(ctx, buildDSN(dbConfig), tracerProvider)
+}
+
+func buildDSN(dbConfig *config.DBConfig) string {
+	return db.BuildDSN(
 		dbConfig.Host.Value,
 		dbConfig.Port.Value,
 		dbConfig.User.Value,
 		dbConfig.Password.Value.GetValue(),
 		dbConfig.Name.Value,
 	)
-	return db.CreatePool(ctx, dsn)
 }
 
-func closeDBConnection(logger *log.Logger, conn *pgxpool.Pool) {
-	logger.Println("DB: closing connection...")
+func closeDBConnection(logger *slog.Logger, conn *pgxpool.Pool) {
+	logger.Info("DB: closing connection...")
 	conn.Close()
-	logger.Println("DB: connection closed")
+	logger.Info("DB: connection closed")
+}
+
+func closeSQLiteConnection(logger *slog.Logger, conn *sql.DB) {
+	logger.Info("SQLite: closing connection...")
+	if err := conn.Close(); err != nil {
+		logger.Error("SQLite: closing connection failed", "error", err)
+		return
+	}
+	logger.Info("SQLite: connection closed")
 }
 
-func createNoteRepo(logger *log.Logger, dbConn *pgxpool.Pool) *db.NoteRepository {
+func createNoteRepo(logger *slog.Logger, dbConn *pgxpool.Pool) *db.NoteRepository {
 	return db.NewNoteRepository(logger, db.New(dbConn))
 }
 
-func createNoteService(logger *log.Logger, repo *db.NoteRepository) *service.NoteService {
-	return service.New(logger, repo, &service.B58IDEncDec{})
+func createRevealTokens(logger *slog.Logger, revealConfig *config.RevealConfig) (*service.RevealTokens, error) {
+	secret := []byte(revealConfig.Secret.Value.GetValue())
+	if len(secret) == 0 {
+		logger.Warn("reveal: no secret configured, using a random one. Tokens will not survive a restart")
+		secret = make([]byte, 32)
+		if _, err := rand.Read(secret); err != nil {
+			return nil, fmt.Errorf("reveal secret generation: %w", err)
+		}
+	}
+	return service.NewRevealTokens(secret, revealConfig.TokenTTL.Value), nil
+}
+
+type idEncDec interface {
+	Encode(intID uint64) (string, error)
+	Decode(strID string) (uint64, error)
+	Validate(strID string) bool
+}
+
+func createIDEncDec(idConfig *config.IDConfig, formatConfig *config.IDFormatConfig) (idEncDec, error) {
+	format, err := createIDFormat(formatConfig)
+	if err != nil {
+		return nil, err
+	}
+	if idConfig.Encoder.Value == config.IDEncoderFeistel {
+		secret := []byte(idConfig.Secret.Value.GetValue())
+		return service.NewFeistelIDEncDec(format, secret, idConfig.LegacyMaxID.Value)
+	}
+	return format, nil
+}
+
+func createIDFormat(formatConfig *config.IDFormatConfig) (*encdec.IDFormat, error) {
+	return encdec.NewIDFormat(
+		formatConfig.Alphabet.Value,
+		int(formatConfig.MinLength.Value),
+		formatConfig.Separator.Value,
+		int(formatConfig.GroupSize.Value),
+	)
+}
+
+func createNoteService(
+	logger *slog.Logger,
+	lockoutConfig *config.LockoutConfig,
+	noteConfig *config.NoteConfig,
+	repo noteRepository,
+	idEncDec idEncDec,
+	revealTokens *service.RevealTokens,
+	noteMetrics *metrics.NoteMetrics,
+	tracerProvider trace.TracerProvider,
+) *service.NoteService {
+	onLockout := func(ctx context.Context, failedAttempts uint) {
+		logger.WarnContext(ctx, "note service: note burnt after too many failed attempts", "failed_attempts", failedAttempts)
+	}
+	return service.New(
+		logger, repo, idEncDec, revealTokens,
+		service.WithLockout(uint(lockoutConfig.MaxFailedAttempts.Value), onLockout),
+		service.WithNoteLimits(noteConfig.Limits()),
+		service.WithMetrics(noteMetrics),
+		service.WithTracerProvider(tracerProvider),
+	)
+}
+
+func createNoteReaper(
+	logger *slog.Logger,
+	reaperConfig *config.ReaperConfig,
+	repo noteRepository,
+	noteMetrics *metrics.NoteMetrics,
+) *service.Reaper {
+	return service.NewReaper(
+		logger, repo, reaperConfig.Interval.Value, reaperConfig.BatchSize.Value,
+		service.WithReaperMetrics(noteMetrics),
+	)
+}
+
+func startReaper(ctx context.Context, reaper *service.Reaper) <-chan struct{} {
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		reaper.Run(ctx)
+	}()
+	return done
+}
+
+func createJSONResponseWriter(logger *slog.Logger) *response.JSONResponseWriter {
+	return response.NewJSONWriter(logger, encdec.NewJSONEncoder())
 }
 
-func createAPI(logger *log.Logger, service *service.NoteService) *http.ServeMux {
+func createAPI(
+	logger *slog.Logger,
+	jsonResponseWriter *response.JSONResponseWriter,
+	httpMetrics *metrics.HTTPMetrics,
+	tracerProvider trace.TracerProvider,
+	service *service.NoteService,
+	adminRouter http.Handler,
+) http.Handler {
 	jsonRequestReader := request.NewJSONReader(logger, encdec.NewJSONDecoder())
-	jsonResponseWriter := response.NewJSONWriter(logger, encdec.NewJSONEncoder())
 	validatorFactory := func() common.Validator { return validator.New() }
 	noteAPI := note.NewRouter(logger, jsonRequestReader, jsonResponseWriter, validatorFactory, service)
-	return router.New(logger, noteAPI)
+	return router.New(logger, jsonResponseWriter, httpMetrics, tracerProvider, noteAPI, adminRouter)
 }
 
-func createServer(
-	logger *log.Logger,
-	serverConfig *config.ServerConfig,
-	api http.Handler,
-) *http.Server {
+func createServer(serverConfig *config.ServerConfig, api http.Handler) *http.Server {
 	maxBytes := 1_048_576
 	return &http.Server{
 		Addr:    fmt.Sprintf(":%d", serverConfig.Port.Value),
@@ -113,24 +410,55 @@ func createServer(
 	}
 }
 
-func startServer(
-	logger *log.Logger,
-	ctx context.Context,
-	serverConfig *config.ServerConfig,
-	api http.Handler,
-) error {
-	srv := createServer(logger, serverConfig, api)
+func createAdminServer(serverConfig *config.ServerConfig, adminAPI http.Handler) *http.Server {
+	return &http.Server{
+		Addr:    fmt.Sprintf(":%d", serverConfig.AdminPort.Value),
+		Handler: adminAPI,
+	}
+}
+
+// startAdminServer serves the admin API in the background. The returned func stops the server.
+func startAdminServer(logger *slog.Logger, serverConfig *config.ServerConfig, adminAPI http.Handler) func() {
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		if err := startServer(logger, ctx, createAdminServer(serverConfig, adminAPI), func() {}); err != nil {
+			logger.Error("admin server: failed", "error", err)
+		}
+	}()
+	return func() {
+		cancel()
+		<-done
+	}
+}
+
+// drain returns the beforeShutdown func of the public server. It fails readiness probes and then waits for delay,
+// so that load balancers stop sending requests before the server stops accepting them.
+func drain(logger *slog.Logger, healthAPI *health.API, delay time.Duration) func() {
+	return func() {
+		healthAPI.StartDraining()
+		if delay > 0 {
+			logger.Info("server: draining...", "delay", delay)
+			time.Sleep(delay)
+		}
+	}
+}
+
+// startServer serves until ctx is done. beforeShutdown is called right before the server starts draining.
+func startServer(logger *slog.Logger, ctx context.Context, srv *http.Server, beforeShutdown func()) error {
 	srvError := make(chan error, 1)
 	go func() {
-		logger.Printf("server: starting on %s", srv.Addr)
+		logger.Info("server: starting", "addr", srv.Addr)
 		srvError <- srv.ListenAndServe()
 	}()
 
 	select {
 	case <-ctx.Done():
+		beforeShutdown()
 		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
 		defer cancel()
-		logger.Printf("server: shutting down...")
+		logger.Info("server: shutting down...", "addr", srv.Addr)
 		if err := srv.Shutdown(ctx); err != nil {
 			return err
 		}
@@ -139,6 +467,6 @@ func startServer(
 			return err
 		}
 	}
-	logger.Println("server: shut down")
+	logger.Info("server: shut down", "addr", srv.Addr)
 	return nil
 }
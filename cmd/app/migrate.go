@@ -0,0 +1,84 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"github.com/ledorub/snote-api/internal/config"
+	"github.com/ledorub/snote-api/internal/db"
+	"log/slog"
+	"strconv"
+)
+
+const migrateUsage = "usage: snote [flags] migrate up | down [N] | status | force VERSION"
+
+// runMigrate executes the migrate subcommand with the arguments following it.
+func runMigrate(logger *slog.Logger, cfg *config.Config, args []string) error {
+	if driver := cfg.Storage.Driver.Value; driver != "" && driver != config.StorageDriverPostgres {
+		return fmt.Errorf("migrate: migrations apply to the %q storage only", config.StorageDriverPostgres)
+	}
+	if len(args) == 0 {
+		return errors.New(migrateUsage)
+	}
+
+	migrator, err := createMigrator(logger, &cfg.DB)
+	if err != nil {
+		return err
+	}
+	defer migrator.Close()
+
+	switch args[0] {
+	case "up":
+		return migrator.Up()
+	case "down":
+		steps := uint64(1)
+		if len(args) > 1 {
+			if steps, err = strconv.ParseUint(args[1], 10, 0); err != nil || steps == 0 {
+				return fmt.Errorf("migrate: invalid number of steps %q", args[1])
+			}
+		}
+		return migrator.Down(uint(steps))
+	case "status":
+		status, err := migrator.Status()
+		if err != nil {
+			return err
+		}
+		logger.Info(
+			"migrate: status",
+			"version", status.Version, "latest_version", status.LatestVersion, "dirty", status.Dirty,
+		)
+		return nil
+	case "force":
+		if len(args) < 2 {
+			return errors.New(migrateUsage)
+		}
+		version, err := strconv.ParseUint(args[1], 10, 0)
+		if err != nil {
+			return fmt.Errorf("migrate: invalid version %q", args[1])
+		}
+		return migrator.Force(uint(version))
+	default:
+		return errors.New(migrateUsage)
+	}
+}
+
+func createMigrator(logger *slog.Logger, dbConfig *config.DBConfig) (*db.Migrator, error) {
+	return db.NewMigrator(logger, buildDSN(dbConfig))
+}
+
+// autoMigrate applies pending migrations on startup if enabled.
+func autoMigrate(logger *slog.Logger, dbConfig *config.DBConfig) error {
+	if !dbConfig.AutoMigrate.Value {
+		return nil
+	}
+	logger.Info("migrate: applying pending migrations...")
+	migrator, err := createMigrator(logger, dbConfig)
+	if err != nil {
+		return err
+	}
+	defer migrator.Close()
+	if err = migrator.Up(); err != nil {
+		return err
+	}
+	logger.Info("migrate: done")
+	return nil
+}
@@ -0,0 +1,78 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"github.com/ledorub/snote-api/internal/config"
+	"log/slog"
+	"os"
+	"os/signal"
+	"strings"
+	"syscall"
+)
+
+// configReloader reloads the config on SIGHUP and applies it if only reloadable values have changed.
+type configReloader struct {
+	logger  *slog.Logger
+	loader  *config.Loader
+	current *config.Config
+	apply   func(cfg *config.Config)
+}
+
+func newConfigReloader(
+	logger *slog.Logger,
+	loader *config.Loader,
+	current *config.Config,
+	apply func(cfg *config.Config),
+) *configReloader {
+	return &configReloader{logger: logger, loader: loader, current: current, apply: apply}
+}
+
+func (r *configReloader) Run(ctx context.Context) {
+	hup := make(chan os.Signal, 1)
+	signal.Notify(hup, syscall.SIGHUP)
+	defer signal.Stop(hup)
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-hup:
+			if err := r.reload(); err != nil {
+				r.logger.Error("config reload: failed", "error", err)
+			}
+		}
+	}
+}
+
+func (r *configReloader) reload() error {
+	r.logger.Info("config reload: reloading...")
+	newCfg, err := r.loader.Load()
+	if err != nil {
+		return err
+	}
+	if err = newCfg.Validate(); err != nil {
+		return fmt.Errorf("invalid config:\n%w", err)
+	}
+
+	changes := r.current.Diff(newCfg)
+	if len(changes) == 0 {
+		r.logger.Info("config reload: nothing changed")
+		return nil
+	}
+	var nonReloadable []string
+	for _, change := range changes {
+		r.logger.Info("config reload: changed", "path", change.Path, "old", change.Old, "new", change.New)
+		if !change.Reloadable {
+			nonReloadable = append(nonReloadable, change.Path)
+		}
+	}
+	if len(nonReloadable) > 0 {
+		return fmt.Errorf("rejected, restart required to change %s", strings.Join(nonReloadable, ", "))
+	}
+
+	r.apply(newCfg)
+	r.current = newCfg
+	r.logger.Info("config reload: applied")
+	return nil
+}
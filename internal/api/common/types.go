@@ -19,15 +19,17 @@ type RequestReader interface {
 
 type ResponseWriter interface {
 	Write(http.ResponseWriter, *http.Request, int, any)
-	WriteError(http.ResponseWriter, *http.Request, int, []map[string]any)
 	WriteServerError(http.ResponseWriter, *http.Request, error)
 	WriteNotFound(http.ResponseWriter, *http.Request)
+	WriteForbidden(http.ResponseWriter, *http.Request, error)
+	WriteServiceUnavailable(http.ResponseWriter, *http.Request, error)
 	WriteBadRequest(http.ResponseWriter, *http.Request, error)
 	WriteValidationError(http.ResponseWriter, *http.Request, []error)
 }
 
 type Validator interface {
 	Check(bool, string)
+	CheckField(ok bool, pointer, code, message string)
 	CheckIsValid() bool
 	GetErrors() []validator.ValidationError
 }
@@ -36,5 +38,8 @@ type ValidatorFactory = func() Validator
 
 type NoteService interface {
 	CreateNote(ctx context.Context, note *internal.Note) (*internal.Note, error)
-	GetNote(ctx context.Context, id string, keyHash string) (*internal.Note, error)
+	RequestReveal(ctx context.Context, id string, keyHash string) (*internal.Note, *internal.RevealToken, error)
+	RevealNote(ctx context.Context, id string, keyHash string, token string) (*internal.Note, error)
+	GetNoteStatus(ctx context.Context, id string, keyHash string) (*internal.Note, error)
+	DeleteNote(ctx context.Context, id string, keyHash string) error
 }
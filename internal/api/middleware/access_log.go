@@ -0,0 +1,47 @@
+package middleware
+
+import (
+	"log/slog"
+	"net/http"
+	"net/url"
+	"time"
+)
+
+// redactedQueryParams are never logged as is since they let anyone holding the logs read notes.
+var redactedQueryParams = []string{"key_hash"}
+
+// AccessLog logs every request once it has been served.
+func AccessLog(logger *slog.Logger) Middleware {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			start := time.Now()
+			rec := &responseRecorder{ResponseWriter: w}
+			next.ServeHTTP(rec, r)
+
+			status := rec.status
+			if status == 0 {
+				status = http.StatusOK
+			}
+			logger.InfoContext(
+				r.Context(),
+				"access",
+				"method", r.Method,
+				"path", r.URL.Path,
+				"query", redactQuery(r.URL.Query()),
+				"status", status,
+				"size", rec.size,
+				"duration", time.Since(start).String(),
+				"remote_addr", r.RemoteAddr,
+			)
+		})
+	}
+}
+
+func redactQuery(query url.Values) string {
+	for _, param := range redactedQueryParams {
+		if query.Has(param) {
+			query.Set(param, "REDACTED")
+		}
+	}
+	return query.Encode()
+}
@@ -0,0 +1,35 @@
+package middleware
+
+import (
+	"net/http"
+	"time"
+)
+
+const unmatchedRoute = "unmatched"
+
+type requestMetrics interface {
+	RequestServed(route string, status int, duration time.Duration)
+}
+
+// Metrics records every request by its route pattern, so that note IDs never end up in labels.
+// It relies on http.ServeMux setting Request.Pattern on the request it is given, so it should wrap a mux directly
+// or through middlewares passing the request on as is.
+func Metrics(metrics requestMetrics) Middleware {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			start := time.Now()
+			rec := &responseRecorder{ResponseWriter: w}
+			next.ServeHTTP(rec, r)
+
+			status := rec.status
+			if status == 0 {
+				status = http.StatusOK
+			}
+			route := r.Pattern
+			if route == "" {
+				route = unmatchedRoute
+			}
+			metrics.RequestServed(route, status, time.Since(start))
+		})
+	}
+}
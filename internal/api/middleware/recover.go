@@ -0,0 +1,31 @@
+package middleware
+
+import (
+	"errors"
+	"github.com/ledorub/snote-api/internal/api/common"
+	"log/slog"
+	"net/http"
+	"runtime/debug"
+)
+
+var errInternal = errors.New("internal server error")
+
+// Recover turns panics into server errors, so that a client gets a JSON error instead of a dropped connection.
+func Recover(logger *slog.Logger, responseWriter common.ResponseWriter) Middleware {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			defer func() {
+				recovered := recover()
+				if recovered == nil {
+					return
+				}
+				if recovered == http.ErrAbortHandler {
+					panic(recovered)
+				}
+				logger.ErrorContext(r.Context(), "panic", "panic", recovered, "stack", string(debug.Stack()))
+				responseWriter.WriteServerError(w, r, errInternal)
+			}()
+			next.ServeHTTP(w, r)
+		})
+	}
+}
@@ -0,0 +1,58 @@
+package middleware
+
+import (
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/propagation"
+	semconv "go.opentelemetry.io/otel/semconv/v1.26.0"
+	"go.opentelemetry.io/otel/trace"
+	"net/http"
+	"strings"
+)
+
+const tracerName = "github.com/ledorub/snote-api/internal/api/middleware"
+
+// Tracing starts a server span for every request, continuing the trace of the caller if there is one.
+// The span is named after the route pattern, so that neither note IDs nor key hashes end up in it.
+// Like Metrics, it relies on http.ServeMux setting Request.Pattern on the request it is given.
+func Tracing(tracerProvider trace.TracerProvider) Middleware {
+	tracer := tracerProvider.Tracer(tracerName)
+	propagator := propagation.TraceContext{}
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			ctx := propagator.Extract(r.Context(), propagation.HeaderCarrier(r.Header))
+			ctx, span := tracer.Start(
+				ctx,
+				r.Method,
+				trace.WithSpanKind(trace.SpanKindServer),
+				trace.WithAttributes(semconv.HTTPRequestMethodKey.String(r.Method)),
+			)
+			defer span.End()
+
+			r = r.WithContext(ctx)
+			rec := &responseRecorder{ResponseWriter: w}
+			next.ServeHTTP(rec, r)
+
+			status := rec.status
+			if status == 0 {
+				status = http.StatusOK
+			}
+			route := routeOf(r.Pattern)
+			if route == "" {
+				route = unmatchedRoute
+			}
+			span.SetName(r.Method + " " + route)
+			span.SetAttributes(semconv.HTTPRoute(route), semconv.HTTPResponseStatusCode(status))
+			if status >= http.StatusInternalServerError {
+				span.SetStatus(codes.Error, http.StatusText(status))
+			}
+		})
+	}
+}
+
+// routeOf strips the method off a ServeMux pattern, e.g. "GET /{noteID}" becomes "/{noteID}".
+func routeOf(pattern string) string {
+	if _, route, hasMethod := strings.Cut(pattern, " "); hasMethod {
+		return route
+	}
+	return pattern
+}
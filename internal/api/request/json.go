@@ -4,7 +4,7 @@ import (
 	"errors"
 	"fmt"
 	"io"
-	"log"
+	"log/slog"
 	"net/http"
 )
 
@@ -13,7 +13,7 @@ type jsonDecoder interface {
 }
 
 type JSONRequestReader struct {
-	logger  *log.Logger
+	logger  *slog.Logger
 	decoder jsonDecoder
 }
 
@@ -26,6 +26,6 @@ func (reader *JSONRequestReader) Read(data io.Reader, dst any) error {
 	return err
 }
 
-func NewJSONReader(logger *log.Logger, decoder jsonDecoder) *JSONRequestReader {
+func NewJSONReader(logger *slog.Logger, decoder jsonDecoder) *JSONRequestReader {
 	return &JSONRequestReader{logger: logger, decoder: decoder}
 }
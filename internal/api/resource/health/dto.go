@@ -0,0 +1,5 @@
+package health
+
+type statusResponse struct {
+	Status string `json:"status"`
+}
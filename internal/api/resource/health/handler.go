@@ -0,0 +1,53 @@
+package health
+
+import (
+	"context"
+	"errors"
+	"github.com/ledorub/snote-api/internal/api/common"
+	"log/slog"
+	"net/http"
+	"sync/atomic"
+)
+
+var (
+	errDraining    = errors.New("server is shutting down")
+	errUnavailable = errors.New("storage is unavailable")
+)
+
+// ReadinessCheck reports whether the dependencies required to serve notes are available.
+type ReadinessCheck func(ctx context.Context) error
+
+type API struct {
+	logger         *slog.Logger
+	responseWriter common.ResponseWriter
+	checkReadiness ReadinessCheck
+	isDraining     atomic.Bool
+}
+
+func NewAPI(logger *slog.Logger, responseWriter common.ResponseWriter, checkReadiness ReadinessCheck) *API {
+	return &API{logger: logger, responseWriter: responseWriter, checkReadiness: checkReadiness}
+}
+
+// Live reports that the process is up and serving requests.
+func (api *API) Live(w http.ResponseWriter, r *http.Request) {
+	api.responseWriter.Write(w, r, http.StatusOK, statusResponse{Status: "ok"})
+}
+
+// Ready reports whether notes can be served. It fails once the server starts draining.
+func (api *API) Ready(w http.ResponseWriter, r *http.Request) {
+	if api.isDraining.Load() {
+		api.responseWriter.WriteServiceUnavailable(w, r, errDraining)
+		return
+	}
+	if err := api.checkReadiness(r.Context()); err != nil {
+		api.logger.WarnContext(r.Context(), "health: not ready", "error", err)
+		api.responseWriter.WriteServiceUnavailable(w, r, errUnavailable)
+		return
+	}
+	api.responseWriter.Write(w, r, http.StatusOK, statusResponse{Status: "ready"})
+}
+
+// StartDraining makes the readiness probe fail, so that no new traffic is routed to the server.
+func (api *API) StartDraining() {
+	api.isDraining.Store(true)
+}
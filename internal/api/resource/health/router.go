@@ -0,0 +1,15 @@
+package health
+
+import "net/http"
+
+const (
+	LivenessPath  = "/healthz"
+	ReadinessPath = "/readyz"
+)
+
+func NewRouter(api *API) *http.ServeMux {
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET "+LivenessPath, api.Live)
+	mux.HandleFunc("GET "+ReadinessPath, api.Ready)
+	return mux
+}
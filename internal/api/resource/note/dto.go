@@ -0,0 +1,72 @@
+package note
+
+import (
+	"github.com/ledorub/snote-api/internal"
+	"time"
+)
+
+const (
+	noteContentType     = "text/plain"
+	noteContentEncoding = "utf-8"
+)
+
+type noteCreateRequest struct {
+	Content           string        `json:"content"`
+	ExpiresAt         time.Time     `json:"expiresAt"`
+	ExpiresAtTimezone string        `json:"expiresAtTimezone"`
+	ExpiresIn         time.Duration `json:"expiresIn"`
+	KeyHash           string        `json:"keyHash"`
+	MaxViews          uint          `json:"maxViews"`
+}
+
+type noteRevealRequest struct {
+	KeyHash     string `json:"keyHash"`
+	RevealToken string `json:"revealToken"`
+}
+
+type noteResponse struct {
+	ID                string    `json:"id"`
+	Content           *string   `json:"content,omitempty"`
+	ContentType       string    `json:"contentType,omitempty"`
+	ContentEncoding   string    `json:"contentEncoding,omitempty"`
+	ExpiresAt         time.Time `json:"expiresAt"`
+	ExpiresAtTimeZone string    `json:"expiresAtTimeZone"`
+	KeyHash           string    `json:"keyHash"`
+	ViewsRemaining    uint      `json:"viewsRemaining"`
+}
+
+func newNoteResponse(note *internal.Note) *noteResponse {
+	return &noteResponse{
+		ID:                note.ID,
+		ExpiresAt:         note.ExpiresAt,
+		ExpiresAtTimeZone: note.ExpiresAtTimeZone.String(),
+		KeyHash:           note.KeyHash,
+		ViewsRemaining:    note.ViewsRemaining,
+	}
+}
+
+func newNoteWithContentResponse(note *internal.Note) *noteResponse {
+	resp := newNoteResponse(note)
+	resp.Content = note.Content
+	resp.ContentType = noteContentType
+	resp.ContentEncoding = noteContentEncoding
+	return resp
+}
+
+type revealTokenResponse struct {
+	ID                   string    `json:"id"`
+	RevealToken          string    `json:"revealToken"`
+	RevealTokenExpiresAt time.Time `json:"revealTokenExpiresAt"`
+	ExpiresAt            time.Time `json:"expiresAt"`
+	ExpiresAtTimeZone    string    `json:"expiresAtTimeZone"`
+}
+
+func newRevealTokenResponse(note *internal.Note, token *internal.RevealToken) *revealTokenResponse {
+	return &revealTokenResponse{
+		ID:                   note.ID,
+		RevealToken:          token.Value,
+		RevealTokenExpiresAt: token.ExpiresAt,
+		ExpiresAt:            note.ExpiresAt,
+		ExpiresAtTimeZone:    note.ExpiresAtTimeZone.String(),
+	}
+}
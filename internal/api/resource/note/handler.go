@@ -4,15 +4,15 @@ import (
 	"errors"
 	"github.com/ledorub/snote-api/internal"
 	"github.com/ledorub/snote-api/internal/api/common"
+	"github.com/ledorub/snote-api/internal/logger"
 	"github.com/ledorub/snote-api/internal/service"
 	"github.com/ledorub/snote-api/internal/validator"
-	"log"
+	"log/slog"
 	"net/http"
-	"time"
 )
 
 type API struct {
-	logger           *log.Logger
+	logger           *slog.Logger
 	requestReader    common.RequestReader
 	responseWriter   common.ResponseWriter
 	validatorFactory common.ValidatorFactory
@@ -20,7 +20,7 @@ type API struct {
 }
 
 func NewAPI(
-	logger *log.Logger,
+	logger *slog.Logger,
 	requestReader common.RequestReader,
 	responseWriter common.ResponseWriter,
 	validatorFactory common.ValidatorFactory,
@@ -36,28 +36,22 @@ func NewAPI(
 }
 
 func (api *API) Create(w http.ResponseWriter, r *http.Request) {
-	type noteCreate struct {
-		Content           string        `json:"content"`
-		ExpiresAt         time.Time     `json:"expiresAt"`
-		ExpiresAtTimezone string        `json:"expiresAtTimezone"`
-		ExpiresIn         time.Duration `json:"expiresIn"`
-		KeyHash           string        `json:"keyHash"`
-	}
-	noteData := noteCreate{}
+	noteData := noteCreateRequest{}
 	if err := api.requestReader.Read(r.Body, &noteData); err != nil {
 		api.responseWriter.WriteBadRequest(w, r, err)
 		return
 	}
 
 	v := api.validatorFactory()
-	v.Check(noteData.Content != "", "content must not be empty")
-	v.Check(len(noteData.KeyHash) != 0, "key hash must not be empty")
+	v.CheckField(noteData.Content != "", "/content", internal.CodeContentRequired, "content must not be empty")
+	v.CheckField(len(noteData.KeyHash) != 0, "/keyHash", internal.CodeKeyHashRequired, "key hash must not be empty")
 
 	isExpiresInSet := noteData.ExpiresIn != 0
 	isExpiresAtSet := !noteData.ExpiresAt.IsZero() && noteData.ExpiresAtTimezone != ""
 	expirationDateConflict := isExpiresInSet && isExpiresAtSet
-	v.Check(
+	v.CheckField(
 		!expirationDateConflict && (isExpiresInSet || isExpiresAtSet),
+		"", internal.CodeExpirationConflict,
 		"either expiresIn or both expiresAt and expiresAtTimezone should be provided",
 	)
 	if !v.CheckIsValid() {
@@ -75,6 +69,7 @@ func (api *API) Create(w http.ResponseWriter, r *http.Request) {
 		noteData.ExpiresAt,
 		noteData.ExpiresAtTimezone,
 		noteData.KeyHash,
+		noteData.MaxViews,
 	)
 	if err != nil {
 		api.responseWriter.WriteValidationError(w, r, []error{err})
@@ -85,36 +80,103 @@ func (api *API) Create(w http.ResponseWriter, r *http.Request) {
 	if err != nil {
 		var validationError validator.ValidationError
 		if errors.As(err, &validationError) {
-			api.responseWriter.WriteValidationError(w, r, []error{validationError})
+			api.responseWriter.WriteValidationError(w, r, []error{err})
 			return
 		}
 		api.responseWriter.WriteServerError(w, r, err)
 		return
 	}
 
-	type noteCreateResponse struct {
-		ID                string    `json:"id"`
-		ExpiresAt         time.Time `json:"expiresAt"`
-		ExpiresAtTimeZone string    `json:"expiresAtTimeZone"`
-		KeyHash           string    `json:"keyHash"`
+	api.responseWriter.Write(w, r, http.StatusCreated, newNoteResponse(note))
+}
+
+// Read hands out a reveal token for the note. The note itself is returned and burnt by Reveal,
+// so that link unfurlers prefetching the URL do not destroy it.
+func (api *API) Read(w http.ResponseWriter, r *http.Request) {
+	noteID := r.PathValue("noteID")
+	r = withNoteID(r, noteID)
+	keyHash := r.URL.Query().Get("key_hash")
+
+	v := api.validatorFactory()
+	v.CheckField(noteID != "", "", internal.CodeNoteIDRequired, "note ID must not be empty")
+	v.CheckField(keyHash != "", "", internal.CodeKeyHashRequired, "key hash must not be empty")
+	if !v.CheckIsValid() {
+		var validationErrors []error
+		for _, err := range v.GetErrors() {
+			validationErrors = append(validationErrors, err)
+		}
+		api.responseWriter.WriteValidationError(w, r, validationErrors)
+		return
 	}
-	noteResponse := noteCreateResponse{
-		ID:                note.ID,
-		ExpiresAt:         note.ExpiresAt,
-		ExpiresAtTimeZone: note.ExpiresAtTimeZone.String(),
-		KeyHash:           note.KeyHash,
+
+	note, token, err := api.noteService.RequestReveal(r.Context(), noteID, keyHash)
+	if err != nil {
+		var validationError validator.ValidationError
+		switch {
+		case errors.Is(err, service.ErrDoesNotExist):
+			api.responseWriter.WriteNotFound(w, r)
+		case errors.As(err, &validationError):
+			api.responseWriter.WriteValidationError(w, r, []error{err})
+		default:
+			api.responseWriter.WriteServerError(w, r, err)
+		}
+		return
+	}
+	api.responseWriter.Write(w, r, http.StatusOK, newRevealTokenResponse(note, token))
+}
+
+func (api *API) Reveal(w http.ResponseWriter, r *http.Request) {
+	noteID := r.PathValue("noteID")
+	r = withNoteID(r, noteID)
+	revealData := noteRevealRequest{}
+	if err := api.requestReader.Read(r.Body, &revealData); err != nil {
+		api.responseWriter.WriteBadRequest(w, r, err)
+		return
+	}
+
+	v := api.validatorFactory()
+	v.CheckField(noteID != "", "", internal.CodeNoteIDRequired, "note ID must not be empty")
+	v.CheckField(revealData.KeyHash != "", "/keyHash", internal.CodeKeyHashRequired, "key hash must not be empty")
+	v.CheckField(
+		revealData.RevealToken != "", "/revealToken", internal.CodeRevealTokenRequired, "reveal token must not be empty",
+	)
+	if !v.CheckIsValid() {
+		var validationErrors []error
+		for _, err := range v.GetErrors() {
+			validationErrors = append(validationErrors, err)
+		}
+		api.responseWriter.WriteValidationError(w, r, validationErrors)
+		return
 	}
 
-	api.responseWriter.Write(w, r, http.StatusCreated, noteResponse)
+	note, err := api.noteService.RevealNote(r.Context(), noteID, revealData.KeyHash, revealData.RevealToken)
+	if err != nil {
+		var validationError validator.ValidationError
+		switch {
+		case errors.Is(err, service.ErrInvalidRevealToken):
+			api.responseWriter.WriteForbidden(w, r, err)
+		case errors.Is(err, service.ErrDoesNotExist):
+			api.responseWriter.WriteNotFound(w, r)
+		case errors.As(err, &validationError):
+			api.responseWriter.WriteValidationError(w, r, pointKeyHashErrorsAtBody(err))
+		default:
+			api.responseWriter.WriteServerError(w, r, err)
+		}
+		return
+	}
+	api.responseWriter.Write(w, r, http.StatusOK, newNoteWithContentResponse(note))
 }
 
-func (api *API) Read(w http.ResponseWriter, r *http.Request) {
+// Status reports whether the note exists and when it expires without burning it.
+// It serves HEAD /{noteID} as well, in which case only the status code is meaningful.
+func (api *API) Status(w http.ResponseWriter, r *http.Request) {
 	noteID := r.PathValue("noteID")
+	r = withNoteID(r, noteID)
 	keyHash := r.URL.Query().Get("key_hash")
 
 	v := api.validatorFactory()
-	v.Check(noteID != "", "note ID must not be empty")
-	v.Check(keyHash != "", "key hash must not be empty")
+	v.CheckField(noteID != "", "", internal.CodeNoteIDRequired, "note ID must not be empty")
+	v.CheckField(keyHash != "", "", internal.CodeKeyHashRequired, "key hash must not be empty")
 	if !v.CheckIsValid() {
 		var validationErrors []error
 		for _, err := range v.GetErrors() {
@@ -124,29 +186,66 @@ func (api *API) Read(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	note, err := api.noteService.GetNote(r.Context(), noteID, keyHash)
+	note, err := api.noteService.GetNoteStatus(r.Context(), noteID, keyHash)
 	if err != nil {
-		if errors.Is(err, service.ErrDoesNotExist) {
+		var validationError validator.ValidationError
+		switch {
+		case errors.Is(err, service.ErrDoesNotExist):
 			api.responseWriter.WriteNotFound(w, r)
-		} else {
+		case errors.As(err, &validationError):
+			api.responseWriter.WriteValidationError(w, r, []error{err})
+		default:
 			api.responseWriter.WriteServerError(w, r, err)
 		}
 		return
 	}
-	noteResponse := &struct {
-		ID                string    `json:"id"`
-		ExpiresAt         time.Time `json:"expiresAt"`
-		ExpiresAtTimeZone string    `json:"expiresAtTimeZone"`
-		KeyHash           string    `json:"keyHash"`
-	}{
-		ID:                note.ID,
-		ExpiresAt:         note.ExpiresAt,
-		ExpiresAtTimeZone: note.ExpiresAtTimeZone.String(),
-		KeyHash:           note.KeyHash,
-	}
-	api.responseWriter.Write(w, r, http.StatusOK, noteResponse)
+	api.responseWriter.Write(w, r, http.StatusOK, newNoteResponse(note))
 }
 
 func (api *API) Delete(w http.ResponseWriter, r *http.Request) {
+	noteID := r.PathValue("noteID")
+	r = withNoteID(r, noteID)
+	keyHash := r.URL.Query().Get("key_hash")
+
+	v := api.validatorFactory()
+	v.CheckField(noteID != "", "", internal.CodeNoteIDRequired, "note ID must not be empty")
+	v.CheckField(keyHash != "", "", internal.CodeKeyHashRequired, "key hash must not be empty")
+	if !v.CheckIsValid() {
+		var validationErrors []error
+		for _, err := range v.GetErrors() {
+			validationErrors = append(validationErrors, err)
+		}
+		api.responseWriter.WriteValidationError(w, r, validationErrors)
+		return
+	}
+
+	if err := api.noteService.DeleteNote(r.Context(), noteID, keyHash); err != nil {
+		var validationError validator.ValidationError
+		switch {
+		case errors.Is(err, service.ErrDoesNotExist), errors.As(err, &validationError):
+			api.responseWriter.WriteNotFound(w, r)
+		default:
+			api.responseWriter.WriteServerError(w, r, err)
+		}
+		return
+	}
 	api.responseWriter.Write(w, r, http.StatusNoContent, nil)
 }
+
+// pointKeyHashErrorsAtBody points the key hash errors of the note service at /keyHash
+// for requests which carry the key hash in their body rather than in the query.
+func pointKeyHashErrorsAtBody(err error) []error {
+	var validationErrors []error
+	for _, validationError := range validator.Errors(err) {
+		if validationError.Code == internal.CodeKeyHashInvalid {
+			validationError.Pointer = "/keyHash"
+		}
+		validationErrors = append(validationErrors, validationError)
+	}
+	return validationErrors
+}
+
+// withNoteID adds the note ID to the attributes logged along with the request.
+func withNoteID(r *http.Request, noteID string) *http.Request {
+	return r.WithContext(logger.WithAttrs(r.Context(), slog.String("note_id", noteID)))
+}
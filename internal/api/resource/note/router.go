@@ -2,12 +2,12 @@ package note
 
 import (
 	"github.com/ledorub/snote-api/internal/api/common"
-	"log"
+	"log/slog"
 	"net/http"
 )
 
 func NewRouter(
-	logger *log.Logger,
+	logger *slog.Logger,
 	requestReader common.RequestReader,
 	responseWriter common.ResponseWriter,
 	validatorFactory common.ValidatorFactory,
@@ -18,6 +18,9 @@ func NewRouter(
 	mux := http.NewServeMux()
 	mux.HandleFunc("POST /", noteAPI.Create)
 	mux.HandleFunc("GET /{noteID}", noteAPI.Read)
+	mux.HandleFunc("HEAD /{noteID}", noteAPI.Status)
+	mux.HandleFunc("GET /{noteID}/status", noteAPI.Status)
+	mux.HandleFunc("POST /{noteID}/reveal", noteAPI.Reveal)
 	mux.HandleFunc("DELETE /{noteID}", noteAPI.Delete)
 	return mux
 }
@@ -1,7 +1,7 @@
 package response
 
 import (
-	"log"
+	"log/slog"
 	"net/http"
 )
 
@@ -10,71 +10,67 @@ const (
 )
 
 type JSONResponseWriter struct {
-	logger  *log.Logger
+	logger  *slog.Logger
 	encoder jsonEncoder
 }
 
 func (writer *JSONResponseWriter) Write(w http.ResponseWriter, r *http.Request, status int, message any) {
+	writer.write(w, r, status, contentTypeJSON, message)
+}
+
+func (writer *JSONResponseWriter) write(
+	w http.ResponseWriter,
+	r *http.Request,
+	status int,
+	contentType string,
+	message any,
+) {
 	encoded, err := writer.encoder.Encode(message)
 	if err != nil {
-		writer.logger.Print(err)
+		writer.logger.ErrorContext(r.Context(), "response encoding failed", "error", err)
 		w.WriteHeader(http.StatusInternalServerError)
 	}
 
-	w.Header().Set("Content-Type", contentTypeJSON)
+	w.Header().Set("Content-Type", contentType)
 	w.WriteHeader(status)
 	w.Write(encoded)
 }
 
-func (writer *JSONResponseWriter) WriteError(w http.ResponseWriter, r *http.Request, status int, errors errorList) {
-	message := errorListToMsg(errors)
-	writer.Write(w, r, status, message)
+func (writer *JSONResponseWriter) writeProblem(w http.ResponseWriter, r *http.Request, details *problem) {
+	writer.write(w, r, details.Status, contentTypeProblemJSON, details)
 }
 
+// WriteServerError logs err and answers with a fixed detail, since err may quote storage errors,
+// queries or the values they failed on.
 func (writer *JSONResponseWriter) WriteServerError(w http.ResponseWriter, r *http.Request, err error) {
-	writer.logger.Print(err)
-	errors := errorList{
-		{"message": err.Error()},
-	}
-	writer.WriteError(w, r, http.StatusInternalServerError, errors)
+	writer.logger.ErrorContext(r.Context(), "server error", "error", err)
+	writer.writeProblem(w, r, newProblem(problemInternal, http.StatusInternalServerError, "request processing failed"))
 }
 
 func (writer *JSONResponseWriter) WriteNotFound(w http.ResponseWriter, r *http.Request) {
-	errors := errorList{
-		{"message": "Not Found"},
-	}
-	writer.WriteError(w, r, http.StatusNotFound, errors)
+	writer.writeProblem(w, r, newProblem(problemNotFound, http.StatusNotFound, ""))
+}
+
+func (writer *JSONResponseWriter) WriteForbidden(w http.ResponseWriter, r *http.Request, err error) {
+	writer.writeProblem(w, r, newProblem(problemForbidden, http.StatusForbidden, err.Error()))
+}
+
+func (writer *JSONResponseWriter) WriteServiceUnavailable(w http.ResponseWriter, r *http.Request, err error) {
+	writer.writeProblem(w, r, newProblem(problemServiceUnavailable, http.StatusServiceUnavailable, err.Error()))
 }
 
 func (writer *JSONResponseWriter) WriteBadRequest(w http.ResponseWriter, r *http.Request, err error) {
-	errors := errorList{
-		{"message": err.Error()},
-	}
-	writer.WriteError(w, r, http.StatusBadRequest, errors)
+	writer.writeProblem(w, r, newProblem(problemMalformedRequest, http.StatusBadRequest, err.Error()))
 }
 
 func (writer *JSONResponseWriter) WriteValidationError(w http.ResponseWriter, r *http.Request, errs []error) {
-	errors := make(errorList, len(errs))
-	for i, err := range errs {
-		errors[i] = map[string]any{
-			"message": err.Error(),
-		}
-	}
-	writer.WriteError(w, r, http.StatusUnprocessableEntity, errors)
+	writer.writeProblem(w, r, newValidationProblem(errs))
 }
 
-func NewJSONWriter(logger *log.Logger, encoder jsonEncoder) *JSONResponseWriter {
+func NewJSONWriter(logger *slog.Logger, encoder jsonEncoder) *JSONResponseWriter {
 	return &JSONResponseWriter{logger: logger, encoder: encoder}
 }
 
 type jsonEncoder interface {
 	Encode(data any) ([]byte, error)
 }
-
-type errorList = []map[string]any
-
-func errorListToMsg(errors errorList) map[string]any {
-	return map[string]any{
-		"details": errors,
-	}
-}
@@ -0,0 +1,60 @@
+package response
+
+import (
+	"github.com/ledorub/snote-api/internal/validator"
+	"net/http"
+)
+
+const (
+	contentTypeProblemJSON string = "application/problem+json"
+	// problemTypePrefix makes problem types URIs as RFC 7807 requires. What follows it never changes.
+	problemTypePrefix = "urn:snote:problem:"
+)
+
+const (
+	problemMalformedRequest   = problemTypePrefix + "malformed-request"
+	problemValidationFailed   = problemTypePrefix + "validation-failed"
+	problemNotFound           = problemTypePrefix + "not-found"
+	problemForbidden          = problemTypePrefix + "forbidden"
+	problemInternal           = problemTypePrefix + "internal-error"
+	problemServiceUnavailable = problemTypePrefix + "service-unavailable"
+)
+
+// problem is an RFC 7807 problem details object.
+type problem struct {
+	Type   string `json:"type"`
+	Title  string `json:"title"`
+	Status int    `json:"status"`
+	Detail string `json:"detail,omitempty"`
+	// Errors lists every validation error of the request.
+	Errors []problemError `json:"errors,omitempty"`
+}
+
+type problemError struct {
+	Code    string `json:"code"`
+	Pointer string `json:"pointer,omitempty"`
+	Detail  string `json:"detail"`
+}
+
+func newProblem(problemType string, status int, detail string) *problem {
+	return &problem{Type: problemType, Title: http.StatusText(status), Status: status, Detail: detail}
+}
+
+// newValidationProblem lists the validation errors errs consist of. Other errors are listed as invalid.
+func newValidationProblem(errs []error) *problem {
+	validationProblem := newProblem(problemValidationFailed, http.StatusUnprocessableEntity, "request is invalid")
+	for _, err := range errs {
+		validationErrors := validator.Errors(err)
+		if len(validationErrors) == 0 {
+			validationErrors = []validator.ValidationError{{Code: validator.CodeInvalid, Message: err.Error()}}
+		}
+		for _, validationError := range validationErrors {
+			validationProblem.Errors = append(validationProblem.Errors, problemError{
+				Code:    validationError.Code,
+				Pointer: validationError.Pointer,
+				Detail:  validationError.Message,
+			})
+		}
+	}
+	return validationProblem
+}
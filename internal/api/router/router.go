@@ -1,12 +1,65 @@
 package router
 
 import (
-	"log"
+	"github.com/ledorub/snote-api/internal/api/common"
+	"github.com/ledorub/snote-api/internal/api/middleware"
+	"github.com/ledorub/snote-api/internal/api/resource/health"
+	"go.opentelemetry.io/otel/trace"
+	"log/slog"
 	"net/http"
+	"time"
 )
 
-func New(logger *log.Logger, noteRouter http.Handler) *http.ServeMux {
+const MetricsPath = "/metrics"
+
+var adminPaths = []string{health.LivenessPath, health.ReadinessPath, MetricsPath}
+
+type requestMetrics interface {
+	RequestServed(route string, status int, duration time.Duration)
+}
+
+// New creates the public API. adminRouter is mounted next to the note API unless it is nil,
+// which is the case if it is served by the admin listener or not served at all.
+func New(
+	logger *slog.Logger,
+	responseWriter common.ResponseWriter,
+	metrics requestMetrics,
+	tracerProvider trace.TracerProvider,
+	noteRouter http.Handler,
+	adminRouter http.Handler,
+) http.Handler {
 	mux := http.NewServeMux()
 	mux.Handle("/", noteRouter)
+	if adminRouter != nil {
+		for _, path := range adminPaths {
+			mux.Handle(path, adminRouter)
+		}
+	}
+	return middleware.Chain(
+		mux,
+		middleware.RequestID(),
+		middleware.Tracing(tracerProvider),
+		middleware.AccessLog(logger),
+		middleware.Metrics(metrics),
+		middleware.Recover(logger, responseWriter),
+	)
+}
+
+// NewAdminRouter serves health probes and metrics.
+func NewAdminRouter(healthRouter http.Handler, metricsHandler http.Handler) *http.ServeMux {
+	mux := http.NewServeMux()
+	mux.Handle(health.LivenessPath, healthRouter)
+	mux.Handle(health.ReadinessPath, healthRouter)
+	mux.Handle("GET "+MetricsPath, metricsHandler)
 	return mux
 }
+
+// NewAdmin creates the API of the admin listener. Probes and scrapes are frequent, so its requests are not
+// access-logged.
+func NewAdmin(logger *slog.Logger, responseWriter common.ResponseWriter, adminRouter http.Handler) http.Handler {
+	return middleware.Chain(
+		adminRouter,
+		middleware.RequestID(),
+		middleware.Recover(logger, responseWriter),
+	)
+}
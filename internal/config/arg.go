@@ -1,21 +1,106 @@
 package config
 
-import "flag"
+import (
+	"flag"
+	"time"
+)
 
 type args struct {
-	port       uint64
-	configFile string
+	configFile   string
+	configFormat string
+	printConfig  bool
+	// given holds the names of the flags set on the command line. Only those override other sources.
+	given map[string]bool
+
+	port                     uint64
+	adminPort                uint64
+	exposeAdmin              bool
+	drainDelay               time.Duration
+	logLevel                 string
+	logFormat                string
+	tracingExporter          string
+	tracingEndpoint          string
+	dbHost                   string
+	dbPort                   uint64
+	dbName                   string
+	dbUser                   string
+	dbPassword               secretString
+	dbAutoMigrate            bool
+	reaperInterval           time.Duration
+	reaperBatchSize          uint64
+	revealSecret             secretString
+	revealTokenTTL           time.Duration
+	lockoutMaxFailedAttempts uint64
+	noteMinExpiresIn         time.Duration
+	noteMaxExpiresIn         time.Duration
+	noteMaxViews             uint64
+	idEncoder                string
+	idSecret                 secretString
+	idLegacyMaxID            uint64
+	idFormatAlphabet         string
+	idFormatMinLength        uint64
+	idFormatSeparator        string
+	idFormatGroupSize        uint64
+	storageDriver            string
+	storagePath              string
+}
+
+func (a *args) isGiven(name string) bool {
+	return a.given[name]
 }
 
 func (l *Loader) loadArgs() *args {
-	return loadArgs()
+	if l.args == nil {
+		l.args = loadArgs()
+	}
+	return l.args
 }
 
 func loadArgs() *args {
-	a := args{}
-	flag.Uint64Var(&a.port, "port", 4000, "API server port")
+	a := args{given: map[string]bool{}}
 	flag.StringVar(&a.configFile, "config-file", "", "Path to a config file")
+	flag.StringVar(
+		&a.configFormat, "config-format", "", "Config file format: yaml, json or toml. Taken from the extension if not set",
+	)
+	flag.BoolVar(&a.printConfig, "print-config", false, "Print the config with the source of every value and exit")
+
+	flag.Uint64Var(&a.port, "port", 0, "API server port")
+	flag.Uint64Var(&a.adminPort, "admin-port", 0, "Port of a separate listener for health endpoints and metrics")
+	flag.BoolVar(&a.exposeAdmin, "expose-admin", false, "Serve health endpoints and metrics next to the API")
+	flag.DurationVar(&a.drainDelay, "drain-delay", 0, "Time to keep serving after readiness starts failing on shutdown")
+	flag.StringVar(&a.logLevel, "log-level", "", "Log level: debug, info, warn or error")
+	flag.StringVar(&a.logFormat, "log-format", "", "Log format: text or json")
+	flag.StringVar(&a.tracingExporter, "tracing-exporter", "", "Span exporter: none, otlp or stdout")
+	flag.StringVar(&a.tracingEndpoint, "tracing-endpoint", "", "OTLP/HTTP collector URL")
+	flag.StringVar(&a.dbHost, "db-host", "", "DB host")
+	flag.Uint64Var(&a.dbPort, "db-port", 0, "DB port")
+	flag.StringVar(&a.dbName, "db-name", "", "DB name")
+	flag.StringVar(&a.dbUser, "db-user", "", "DB user")
+	flag.Var(&a.dbPassword, "db-password", "DB password")
+	flag.BoolVar(&a.dbAutoMigrate, "db-auto-migrate", false, "Apply pending migrations on startup")
+	flag.DurationVar(&a.reaperInterval, "reaper-interval", 0, "Interval between expired note deletions")
+	flag.Uint64Var(&a.reaperBatchSize, "reaper-batch-size", 0, "Max number of expired notes deleted at once")
+	flag.Var(&a.revealSecret, "reveal-secret", "Secret reveal tokens are signed with")
+	flag.DurationVar(&a.revealTokenTTL, "reveal-token-ttl", 0, "Reveal token lifetime")
+	flag.Uint64Var(
+		&a.lockoutMaxFailedAttempts, "lockout-max-failed-attempts", 0, "Failed attempts after which a note is burnt",
+	)
+	flag.DurationVar(&a.noteMinExpiresIn, "note-min-expires-in", 0, "Min lifetime of new notes")
+	flag.DurationVar(&a.noteMaxExpiresIn, "note-max-expires-in", 0, "Max lifetime of new notes")
+	flag.Uint64Var(&a.noteMaxViews, "note-max-views", 0, "Max number of views of new notes")
+	flag.StringVar(&a.idEncoder, "id-encoder", "", "Note ID encoder: b58 or feistel")
+	flag.Var(&a.idSecret, "id-secret", "Secret of the feistel ID encoder")
+	flag.Uint64Var(&a.idLegacyMaxID, "id-legacy-max-id", 0, "Max note ID issued before the feistel ID encoder")
+	flag.StringVar(&a.idFormatAlphabet, "id-format-alphabet", "", "Chars note IDs consist of")
+	flag.Uint64Var(&a.idFormatMinLength, "id-format-min-length", 0, "Min number of note ID chars")
+	flag.StringVar(&a.idFormatSeparator, "id-format-separator", "", "Separator of note ID char groups")
+	flag.Uint64Var(&a.idFormatGroupSize, "id-format-group-size", 0, "Number of chars in a note ID group")
+	flag.StringVar(&a.storageDriver, "storage-driver", "", "Storage driver: postgres, sqlite or memory")
+	flag.StringVar(&a.storagePath, "storage-path", "", "SQLite database file")
 	flag.Parse()
 
+	flag.Visit(func(f *flag.Flag) {
+		a.given[f.Name] = true
+	})
 	return &a
 }
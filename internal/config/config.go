@@ -1,10 +1,17 @@
 package config
 
 import (
+	"errors"
 	"fmt"
+	"github.com/ledorub/snote-api/internal"
 	"github.com/ledorub/snote-api/internal/encdec"
 	"github.com/ledorub/snote-api/internal/validator"
 	"io"
+	"log/slog"
+	"math"
+	"net/url"
+	"os"
+	"time"
 )
 
 type sourceType string
@@ -13,22 +20,129 @@ const (
 	SourceUnset    sourceType = ""
 	FileSource     sourceType = "file"
 	ArgumentSource sourceType = "argument"
+	EnvSource      sourceType = "env"
+	DefaultSource  sourceType = "default"
 )
 
 type Config struct {
-	Source ConfigSource `yaml:"source"`
-	Server ServerConfig `yaml:"server"`
-	DB     DBConfig     `yaml:"db"`
+	Source   ConfigSource   `yaml:"source"`
+	Server   ServerConfig   `yaml:"server"`
+	Log      LogConfig      `yaml:"log"`
+	Tracing  TracingConfig  `yaml:"tracing"`
+	DB       DBConfig       `yaml:"db"`
+	Reaper   ReaperConfig   `yaml:"reaper"`
+	Reveal   RevealConfig   `yaml:"reveal"`
+	Lockout  LockoutConfig  `yaml:"lockout"`
+	Note     NoteConfig     `yaml:"note"`
+	ID       IDConfig       `yaml:"id"`
+	IDFormat IDFormatConfig `yaml:"idFormat"`
+	Storage  StorageConfig  `yaml:"storage"`
+	// PrintConfig is set if the config should be printed instead of running the app.
+	PrintConfig bool `yaml:"-"`
 }
 
-func (cfg *Config) checkErrors() error {
-	if !validator.ValidateValueInRange[uint64](cfg.Server.Port.Value, 1024, 65535) {
-		return fmt.Errorf("invalid port value %d. Should be in-between 1024 and 65535", cfg.Server.Port.Value)
+// Validate checks the whole config and reports every problem found, each prefixed with its config path.
+func (cfg *Config) Validate() error {
+	v := validator.Validator{}
+
+	v.Check(
+		validator.ValidateValueInRange[uint64](cfg.Server.Port.Value, 1024, 65535),
+		fmt.Sprintf("server.port should be in range [1024, 65535], got %d", cfg.Server.Port.Value),
+	)
+	if adminPort := cfg.Server.AdminPort.Value; adminPort != 0 {
+		v.Check(
+			validator.ValidateValueInRange[uint64](adminPort, 1024, 65535),
+			fmt.Sprintf("server.adminPort should be in range [1024, 65535], got %d", adminPort),
+		)
+		v.Check(adminPort != cfg.Server.Port.Value, "server.adminPort should differ from server.port")
+		v.Check(!cfg.Server.ExposeAdmin.Value, "server.exposeAdmin should not be set along with server.adminPort")
 	}
-	if !validator.ValidateValueInRange[uint64](cfg.DB.Port.Value, 1024, 65535) {
-		return fmt.Errorf("invalid DB port value %d. Should be in-between 1024 and 65535", cfg.DB.Port)
+	v.Check(cfg.Server.DrainDelay.Value >= 0, "server.drainDelay should not be negative")
+
+	var level slog.Level
+	if err := level.UnmarshalText([]byte(cfg.Log.Level.Value)); err != nil {
+		v.AddError(fmt.Sprintf("log.level should be one of debug, info, warn or error, got %q", cfg.Log.Level.Value))
 	}
-	return nil
+	if format := cfg.Log.Format.Value; format != LogFormatText && format != LogFormatJSON {
+		v.AddError(fmt.Sprintf("log.format should be either %q or %q, got %q", LogFormatText, LogFormatJSON, format))
+	}
+
+	switch exporter := cfg.Tracing.Exporter.Value; exporter {
+	case TracingExporterNone, TracingExporterStdout:
+	case TracingExporterOTLP:
+		endpoint, err := url.Parse(cfg.Tracing.Endpoint.Value)
+		isValidEndpoint := err == nil && (endpoint.Scheme == "http" || endpoint.Scheme == "https") && endpoint.Host != ""
+		v.Check(
+			isValidEndpoint,
+			fmt.Sprintf("tracing.endpoint should be an http or https URL, got %q", cfg.Tracing.Endpoint.Value),
+		)
+	default:
+		v.AddError(fmt.Sprintf(
+			"tracing.exporter should be one of %q, %q or %q, got %q",
+			TracingExporterNone, TracingExporterOTLP, TracingExporterStdout, exporter,
+		))
+	}
+
+	switch driver := cfg.Storage.Driver.Value; driver {
+	case StorageDriverPostgres:
+		v.Check(cfg.DB.Host.Value != "", "db.host should be provided")
+		v.Check(
+			validator.ValidateValueInRange[uint64](cfg.DB.Port.Value, 1024, 65535),
+			fmt.Sprintf("db.port should be in range [1024, 65535], got %d", cfg.DB.Port.Value),
+		)
+		v.Check(cfg.DB.Name.Value != "", "db.name should be provided")
+		v.Check(cfg.DB.User.Value != "", "db.user should be provided")
+		v.Check(cfg.DB.Password.Value.GetValue() != "", "db.password should be provided")
+	case StorageDriverSQLite:
+		v.Check(cfg.Storage.Path.Value != "", fmt.Sprintf("storage.path should be provided for the %q driver", driver))
+	case StorageDriverMemory:
+	default:
+		v.AddError(fmt.Sprintf(
+			"storage.driver should be one of %q, %q or %q, got %q",
+			StorageDriverPostgres, StorageDriverSQLite, StorageDriverMemory, driver,
+		))
+	}
+
+	v.Check(cfg.Reaper.Interval.Value > 0, "reaper.interval should be positive")
+	v.Check(cfg.Reaper.BatchSize.Value > 0, "reaper.batchSize should be positive")
+	v.Check(cfg.Reveal.TokenTTL.Value > 0, "reveal.tokenTTL should be positive")
+	v.Check(cfg.Lockout.MaxFailedAttempts.Value > 0, "lockout.maxFailedAttempts should be positive")
+	v.Check(cfg.Note.MinExpiresIn.Value >= time.Minute, "note.minExpiresIn should be at least 1m")
+	v.Check(
+		cfg.Note.MaxExpiresIn.Value >= cfg.Note.MinExpiresIn.Value,
+		"note.maxExpiresIn should not be less than note.minExpiresIn",
+	)
+	v.Check(
+		validator.ValidateValueInRange[uint64](cfg.Note.MaxViews.Value, 1, math.MaxInt32),
+		fmt.Sprintf("note.maxViews should be in range [1, %d], got %d", math.MaxInt32, cfg.Note.MaxViews.Value),
+	)
+
+	switch encoder := cfg.ID.Encoder.Value; encoder {
+	case IDEncoderB58:
+	case IDEncoderFeistel:
+		v.Check(
+			cfg.ID.Secret.Value.GetValue() != "",
+			fmt.Sprintf("id.secret should be provided for the %q encoder", encoder),
+		)
+	default:
+		v.AddError(fmt.Sprintf("id.encoder should be either %q or %q, got %q", IDEncoderB58, IDEncoderFeistel, encoder))
+	}
+
+	_, err := encdec.NewIDFormat(
+		cfg.IDFormat.Alphabet.Value,
+		int(cfg.IDFormat.MinLength.Value),
+		cfg.IDFormat.Separator.Value,
+		int(cfg.IDFormat.GroupSize.Value),
+	)
+	if err != nil {
+		v.AddError(fmt.Sprintf("idFormat is invalid: %v", err))
+	}
+
+	var validationErrors []error
+	for _, err := range v.GetErrors() {
+		validationErrors = append(validationErrors, err)
+	}
+	return errors.Join(validationErrors...)
 }
 
 func (cfg *Config) Pretty() (string, error) {
@@ -42,11 +156,52 @@ func (cfg *Config) Pretty() (string, error) {
 
 type ConfigSource struct {
 	ParseArgs bool   `yaml:"parseArgs"`
+	ParseEnv  bool   `yaml:"parseEnv"`
 	File      string `yaml:"file"`
+	Format    string `yaml:"format"`
 }
 
 type ServerConfig struct {
 	Port configValue[uint64] `yaml:"port"`
+	// AdminPort is the port of the listener serving health endpoints and metrics.
+	AdminPort configValue[uint64] `yaml:"adminPort"`
+	// ExposeAdmin serves health endpoints and metrics next to the API if AdminPort is 0.
+	// Otherwise they are not served at all.
+	ExposeAdmin configValue[bool] `yaml:"exposeAdmin"`
+	// DrainDelay is how long the server keeps serving after readiness has started failing on shutdown,
+	// so that load balancers stop sending requests first.
+	DrainDelay configValue[time.Duration] `yaml:"drainDelay"`
+}
+
+const (
+	LogFormatText = "text"
+	LogFormatJSON = "json"
+)
+
+type LogConfig struct {
+	Level  configValue[string] `yaml:"level"`
+	Format configValue[string] `yaml:"format"`
+}
+
+// SlogLevel returns the parsed level, falling back to info for invalid ones.
+func (c *LogConfig) SlogLevel() slog.Level {
+	var level slog.Level
+	if err := level.UnmarshalText([]byte(c.Level.Value)); err != nil {
+		return slog.LevelInfo
+	}
+	return level
+}
+
+const (
+	TracingExporterNone   = "none"
+	TracingExporterOTLP   = "otlp"
+	TracingExporterStdout = "stdout"
+)
+
+type TracingConfig struct {
+	Exporter configValue[string] `yaml:"exporter"`
+	// Endpoint is the URL of the OTLP/HTTP collector. Spans are sent to /v1/traces unless the URL has a path.
+	Endpoint configValue[string] `yaml:"endpoint"`
 }
 
 type DBConfig struct {
@@ -55,6 +210,68 @@ type DBConfig struct {
 	Name     configValue[string]       `yaml:"name"`
 	User     configValue[string]       `yaml:"user"`
 	Password configValue[secretString] `yaml:"password"`
+	// AutoMigrate applies pending migrations on startup.
+	AutoMigrate configValue[bool] `yaml:"autoMigrate"`
+}
+
+type ReaperConfig struct {
+	Interval  configValue[time.Duration] `yaml:"interval"`
+	BatchSize configValue[uint64]        `yaml:"batchSize"`
+}
+
+type RevealConfig struct {
+	Secret   configValue[secretString]  `yaml:"secret"`
+	TokenTTL configValue[time.Duration] `yaml:"tokenTTL"`
+}
+
+type LockoutConfig struct {
+	MaxFailedAttempts configValue[uint64] `yaml:"maxFailedAttempts"`
+}
+
+// NoteConfig bounds the notes which can be created.
+type NoteConfig struct {
+	MinExpiresIn configValue[time.Duration] `yaml:"minExpiresIn"`
+	MaxExpiresIn configValue[time.Duration] `yaml:"maxExpiresIn"`
+	MaxViews     configValue[uint64]        `yaml:"maxViews"`
+}
+
+func (c *NoteConfig) Limits() internal.NoteLimits {
+	return internal.NoteLimits{
+		MinExpiresIn: c.MinExpiresIn.Value,
+		MaxExpiresIn: c.MaxExpiresIn.Value,
+		MaxViews:     uint(c.MaxViews.Value),
+	}
+}
+
+const (
+	IDEncoderB58     = "b58"
+	IDEncoderFeistel = "feistel"
+)
+
+type IDConfig struct {
+	Encoder     configValue[string]       `yaml:"encoder"`
+	Secret      configValue[secretString] `yaml:"secret"`
+	LegacyMaxID configValue[uint64]       `yaml:"legacyMaxID"`
+}
+
+// IDFormatConfig describes how note IDs look.
+type IDFormatConfig struct {
+	Alphabet  configValue[string] `yaml:"alphabet"`
+	MinLength configValue[uint64] `yaml:"minLength"`
+	Separator configValue[string] `yaml:"separator"`
+	GroupSize configValue[uint64] `yaml:"groupSize"`
+}
+
+const (
+	StorageDriverPostgres = "postgres"
+	StorageDriverSQLite   = "sqlite"
+	StorageDriverMemory   = "memory"
+)
+
+type StorageConfig struct {
+	Driver configValue[string] `yaml:"driver"`
+	// Path is the database file of the SQLite storage.
+	Path configValue[string] `yaml:"path"`
 }
 
 type configValue[T any] struct {
@@ -71,8 +288,12 @@ func (cv *configValue[T]) Set(value T, src sourceType) {
 
 type Loader struct {
 	shouldLoadArgs    bool
+	shouldLoadEnv     bool
 	configFile        string
+	configFormat      string
 	configFileDecoder configFileDecoder
+	// args are parsed once, so that the config can be reloaded.
+	args *args
 }
 
 func NewLoader(opts ...LoaderOpt) *Loader {
@@ -80,41 +301,62 @@ func NewLoader(opts ...LoaderOpt) *Loader {
 	for _, opt := range opts {
 		opt(loader)
 	}
-
-	if loader.configFileDecoder == nil {
-		loader.configFileDecoder = encdec.NewYAMLDecoder()
-	}
 	return loader
 }
 
+// Load collects the config from all the enabled sources. A value is taken from the first source that sets it,
+// in the following order: flags, env, file, built-in defaults. The config is not validated, see Config.Validate.
 func (l *Loader) Load() (*Config, error) {
 	cfg := &Config{}
 	setters := configValueSetters{}
 	mapper := newValueMapper(setters, cfg)
 
+	var loadedArgs *args
 	if l.shouldLoadArgs {
-		loadedArgs := l.loadArgs()
+		loadedArgs = l.loadArgs()
 		cfg.Source.ParseArgs = true
-		mapper.mapArgsToConfigValues(loadedArgs)
-
+		cfg.PrintConfig = loadedArgs.printConfig
 		if l.configFile == "" {
 			l.configFile = loadedArgs.configFile
 		}
+		if l.configFormat == "" {
+			l.configFormat = loadedArgs.configFormat
+		}
+	}
+	if l.shouldLoadEnv {
+		if l.configFile == "" {
+			l.configFile = os.Getenv(envPrefix + "CONFIG_FILE")
+		}
+		if l.configFormat == "" {
+			l.configFormat = os.Getenv(envPrefix + "CONFIG_FORMAT")
+		}
 	}
 
+	var fileCfg *configFile
 	if l.configFile != "" {
-		fileCfg, err := l.loadFile()
-		if err != nil {
+		var err error
+		if fileCfg, err = l.loadFile(); err != nil {
 			return nil, err
 		}
 		cfg.Source.File = l.configFile
+		cfg.Source.Format = l.configFormat
+	}
+
+	if loadedArgs != nil {
+		mapper.mapArgsToConfigValues(loadedArgs)
+	}
+	if l.shouldLoadEnv {
+		cfg.Source.ParseEnv = true
+		if err := mapper.mapEnvToConfigValues(); err != nil {
+			return nil, err
+		}
+	}
+	if fileCfg != nil {
 		mapper.mapConfigFileToConfigValues(fileCfg)
 	}
+	mapper.mapDefaultsToConfigValues()
 
 	setters.setValueForAll()
-	if err := cfg.checkErrors(); err != nil {
-		return nil, err
-	}
 	return cfg, nil
 }
 
@@ -126,6 +368,15 @@ func LoadArgs() LoaderOpt {
 	}
 }
 
+// LoadEnv reads SNOTE_-prefixed environment variables, e.g. SNOTE_DB_HOST. SNOTE_CONFIG_FILE and SNOTE_CONFIG_FORMAT
+// point to a config file if none is given otherwise.
+func LoadEnv() LoaderOpt {
+	return func(l *Loader) {
+		l.shouldLoadEnv = true
+	}
+}
+
+// LoadFile reads the config file at path. If decoder is nil, it is picked by the file extension.
 func LoadFile(path string, decoder configFileDecoder) LoaderOpt {
 	return func(l *Loader) {
 		l.configFile = path
@@ -137,21 +388,23 @@ type configFileDecoder interface {
 	Decode(data io.Reader, dst any) error
 }
 
-type configValueSetters map[string]func()
+// configValueSetters holds the setters of every value in the order they were added. Since configValue keeps
+// the first value set, sources should be mapped from the highest precedence to the lowest.
+type configValueSetters map[string][]func()
 
 func (s configValueSetters) addSetterFor(name string, setter func()) {
-	s[name] = setter
+	s[name] = append(s[name], setter)
 }
 
 func (s configValueSetters) setValueFor(name string) {
-	if setter, exists := s[name]; exists {
+	for _, setter := range s[name] {
 		setter()
 	}
 }
 
 func (s configValueSetters) setValueForAll() {
-	for _, setter := range s {
-		setter()
+	for name := range s {
+		s.setValueFor(name)
 	}
 }
 
@@ -166,20 +419,273 @@ func newValueMapper(setters configValueSetters, config *Config) *valueMapper {
 
 func (m *valueMapper) mapArgsToConfigValues(a *args) {
 	src := ArgumentSource
-	mapToConfigValue[uint64](m.setters, "port", src, &a.port, &m.config.Server.Port)
+	mapArgToConfigValue[uint64](m.setters, a, "port", "port", src, &a.port, &m.config.Server.Port)
+	mapArgToConfigValue[uint64](m.setters, a, "admin_port", "admin-port", src, &a.adminPort, &m.config.Server.AdminPort)
+	mapArgToConfigValue[bool](
+		m.setters, a, "expose_admin", "expose-admin", src, &a.exposeAdmin, &m.config.Server.ExposeAdmin,
+	)
+	mapArgToConfigValue[time.Duration](
+		m.setters, a, "drain_delay", "drain-delay", src, &a.drainDelay, &m.config.Server.DrainDelay,
+	)
+	mapArgToConfigValue[string](m.setters, a, "log_level", "log-level", src, &a.logLevel, &m.config.Log.Level)
+	mapArgToConfigValue[string](m.setters, a, "log_format", "log-format", src, &a.logFormat, &m.config.Log.Format)
+	mapArgToConfigValue[string](
+		m.setters, a, "tracing_exporter", "tracing-exporter", src, &a.tracingExporter, &m.config.Tracing.Exporter,
+	)
+	mapArgToConfigValue[string](
+		m.setters, a, "tracing_endpoint", "tracing-endpoint", src, &a.tracingEndpoint, &m.config.Tracing.Endpoint,
+	)
+	mapArgToConfigValue[string](m.setters, a, "db_host", "db-host", src, &a.dbHost, &m.config.DB.Host)
+	mapArgToConfigValue[uint64](m.setters, a, "db_port", "db-port", src, &a.dbPort, &m.config.DB.Port)
+	mapArgToConfigValue[string](m.setters, a, "db_name", "db-name", src, &a.dbName, &m.config.DB.Name)
+	mapArgToConfigValue[string](m.setters, a, "db_user", "db-user", src, &a.dbUser, &m.config.DB.User)
+	mapArgToConfigValue[secretString](m.setters, a, "db_password", "db-password", src, &a.dbPassword, &m.config.DB.Password)
+	mapArgToConfigValue[bool](
+		m.setters, a, "db_auto_migrate", "db-auto-migrate", src, &a.dbAutoMigrate, &m.config.DB.AutoMigrate,
+	)
+	mapArgToConfigValue[time.Duration](
+		m.setters, a, "reaper_interval", "reaper-interval", src, &a.reaperInterval, &m.config.Reaper.Interval,
+	)
+	mapArgToConfigValue[uint64](
+		m.setters, a, "reaper_batch_size", "reaper-batch-size", src, &a.reaperBatchSize, &m.config.Reaper.BatchSize,
+	)
+	mapArgToConfigValue[secretString](
+		m.setters, a, "reveal_secret", "reveal-secret", src, &a.revealSecret, &m.config.Reveal.Secret,
+	)
+	mapArgToConfigValue[time.Duration](
+		m.setters, a, "reveal_token_ttl", "reveal-token-ttl", src, &a.revealTokenTTL, &m.config.Reveal.TokenTTL,
+	)
+	mapArgToConfigValue[uint64](
+		m.setters, a, "lockout_max_failed_attempts", "lockout-max-failed-attempts", src,
+		&a.lockoutMaxFailedAttempts, &m.config.Lockout.MaxFailedAttempts,
+	)
+	mapArgToConfigValue[time.Duration](
+		m.setters, a, "note_min_expires_in", "note-min-expires-in", src, &a.noteMinExpiresIn, &m.config.Note.MinExpiresIn,
+	)
+	mapArgToConfigValue[time.Duration](
+		m.setters, a, "note_max_expires_in", "note-max-expires-in", src, &a.noteMaxExpiresIn, &m.config.Note.MaxExpiresIn,
+	)
+	mapArgToConfigValue[uint64](
+		m.setters, a, "note_max_views", "note-max-views", src, &a.noteMaxViews, &m.config.Note.MaxViews,
+	)
+	mapArgToConfigValue[string](m.setters, a, "id_encoder", "id-encoder", src, &a.idEncoder, &m.config.ID.Encoder)
+	mapArgToConfigValue[secretString](m.setters, a, "id_secret", "id-secret", src, &a.idSecret, &m.config.ID.Secret)
+	mapArgToConfigValue[uint64](
+		m.setters, a, "id_legacy_max_id", "id-legacy-max-id", src, &a.idLegacyMaxID, &m.config.ID.LegacyMaxID,
+	)
+	mapArgToConfigValue[string](
+		m.setters, a, "id_format_alphabet", "id-format-alphabet", src, &a.idFormatAlphabet, &m.config.IDFormat.Alphabet,
+	)
+	mapArgToConfigValue[uint64](
+		m.setters, a, "id_format_min_length", "id-format-min-length", src,
+		&a.idFormatMinLength, &m.config.IDFormat.MinLength,
+	)
+	mapArgToConfigValue[string](
+		m.setters, a, "id_format_separator", "id-format-separator", src,
+		&a.idFormatSeparator, &m.config.IDFormat.Separator,
+	)
+	mapArgToConfigValue[uint64](
+		m.setters, a, "id_format_group_size", "id-format-group-size", src,
+		&a.idFormatGroupSize, &m.config.IDFormat.GroupSize,
+	)
+	mapArgToConfigValue[string](
+		m.setters, a, "storage_driver", "storage-driver", src, &a.storageDriver, &m.config.Storage.Driver,
+	)
+	mapArgToConfigValue[string](m.setters, a, "storage_path", "storage-path", src, &a.storagePath, &m.config.Storage.Path)
 }
 
 func (m *valueMapper) mapConfigFileToConfigValues(cfgF *configFile) {
 	src := FileSource
-	mapToConfigValue[uint64](m.setters, "port", src, &cfgF.Server.Port, &m.config.Server.Port)
-	mapToConfigValue[string](m.setters, "db_host", src, &cfgF.DB.Host, &m.config.DB.Host)
-	mapToConfigValue[uint64](m.setters, "db_port", src, &cfgF.DB.Port, &m.config.DB.Port)
-	mapToConfigValue[string](m.setters, "db_name", src, &cfgF.DB.Name, &m.config.DB.Name)
-	mapToConfigValue[string](m.setters, "db_user", src, &cfgF.DB.User, &m.config.DB.User)
-	mapToConfigValue[secretString](m.setters, "db_password", src, &cfgF.DB.Password, &m.config.DB.Password)
+	mapToConfigValue[uint64](m.setters, "port", src, cfgF.Server.Port, &m.config.Server.Port)
+	mapToConfigValue[uint64](m.setters, "admin_port", src, cfgF.Server.AdminPort, &m.config.Server.AdminPort)
+	mapToConfigValue[bool](m.setters, "expose_admin", src, cfgF.Server.ExposeAdmin, &m.config.Server.ExposeAdmin)
+	mapToConfigValue[time.Duration](
+		m.setters, "drain_delay", src, (*time.Duration)(cfgF.Server.DrainDelay), &m.config.Server.DrainDelay,
+	)
+	mapToConfigValue[string](m.setters, "log_level", src, cfgF.Log.Level, &m.config.Log.Level)
+	mapToConfigValue[string](m.setters, "log_format", src, cfgF.Log.Format, &m.config.Log.Format)
+	mapToConfigValue[string](m.setters, "tracing_exporter", src, cfgF.Tracing.Exporter, &m.config.Tracing.Exporter)
+	mapToConfigValue[string](m.setters, "tracing_endpoint", src, cfgF.Tracing.Endpoint, &m.config.Tracing.Endpoint)
+	mapToConfigValue[string](m.setters, "db_host", src, cfgF.DB.Host, &m.config.DB.Host)
+	mapToConfigValue[uint64](m.setters, "db_port", src, cfgF.DB.Port, &m.config.DB.Port)
+	mapToConfigValue[string](m.setters, "db_name", src, cfgF.DB.Name, &m.config.DB.Name)
+	mapToConfigValue[string](m.setters, "db_user", src, cfgF.DB.User, &m.config.DB.User)
+	mapToConfigValue[secretString](m.setters, "db_password", src, cfgF.DB.Password, &m.config.DB.Password)
+	mapToConfigValue[bool](m.setters, "db_auto_migrate", src, cfgF.DB.AutoMigrate, &m.config.DB.AutoMigrate)
+	mapToConfigValue[time.Duration](
+		m.setters, "reaper_interval", src, (*time.Duration)(cfgF.Reaper.Interval), &m.config.Reaper.Interval,
+	)
+	mapToConfigValue[uint64](m.setters, "reaper_batch_size", src, cfgF.Reaper.BatchSize, &m.config.Reaper.BatchSize)
+	mapToConfigValue[secretString](m.setters, "reveal_secret", src, cfgF.Reveal.Secret, &m.config.Reveal.Secret)
+	mapToConfigValue[time.Duration](
+		m.setters, "reveal_token_ttl", src, (*time.Duration)(cfgF.Reveal.TokenTTL), &m.config.Reveal.TokenTTL,
+	)
+	mapToConfigValue[uint64](
+		m.setters, "lockout_max_failed_attempts", src, cfgF.Lockout.MaxFailedAttempts, &m.config.Lockout.MaxFailedAttempts,
+	)
+	mapToConfigValue[time.Duration](
+		m.setters, "note_min_expires_in", src, (*time.Duration)(cfgF.Note.MinExpiresIn), &m.config.Note.MinExpiresIn,
+	)
+	mapToConfigValue[time.Duration](
+		m.setters, "note_max_expires_in", src, (*time.Duration)(cfgF.Note.MaxExpiresIn), &m.config.Note.MaxExpiresIn,
+	)
+	mapToConfigValue[uint64](m.setters, "note_max_views", src, cfgF.Note.MaxViews, &m.config.Note.MaxViews)
+	mapToConfigValue[string](m.setters, "id_encoder", src, cfgF.ID.Encoder, &m.config.ID.Encoder)
+	mapToConfigValue[secretString](m.setters, "id_secret", src, cfgF.ID.Secret, &m.config.ID.Secret)
+	mapToConfigValue[uint64](m.setters, "id_legacy_max_id", src, cfgF.ID.LegacyMaxID, &m.config.ID.LegacyMaxID)
+	mapToConfigValue[string](m.setters, "id_format_alphabet", src, cfgF.IDFormat.Alphabet, &m.config.IDFormat.Alphabet)
+	mapToConfigValue[uint64](m.setters, "id_format_min_length", src, cfgF.IDFormat.MinLength, &m.config.IDFormat.MinLength)
+	mapToConfigValue[string](m.setters, "id_format_separator", src, cfgF.IDFormat.Separator, &m.config.IDFormat.Separator)
+	mapToConfigValue[uint64](m.setters, "id_format_group_size", src, cfgF.IDFormat.GroupSize, &m.config.IDFormat.GroupSize)
+	mapToConfigValue[string](m.setters, "storage_driver", src, cfgF.Storage.Driver, &m.config.Storage.Driver)
+	mapToConfigValue[string](m.setters, "storage_path", src, cfgF.Storage.Path, &m.config.Storage.Path)
+}
+
+func (m *valueMapper) mapEnvToConfigValues() error {
+	src := EnvSource
+	return errors.Join(
+		mapEnvToConfigValue(m.setters, "port", "SERVER_PORT", src, parseEnvUint, &m.config.Server.Port),
+		mapEnvToConfigValue(
+			m.setters, "admin_port", "SERVER_ADMIN_PORT", src, parseEnvUint, &m.config.Server.AdminPort,
+		),
+		mapEnvToConfigValue(
+			m.setters, "expose_admin", "SERVER_EXPOSE_ADMIN", src, parseEnvBool, &m.config.Server.ExposeAdmin,
+		),
+		mapEnvToConfigValue(
+			m.setters, "drain_delay", "SERVER_DRAIN_DELAY", src, parseEnvDuration, &m.config.Server.DrainDelay,
+		),
+		mapEnvToConfigValue(m.setters, "log_level", "LOG_LEVEL", src, parseEnvString, &m.config.Log.Level),
+		mapEnvToConfigValue(m.setters, "log_format", "LOG_FORMAT", src, parseEnvString, &m.config.Log.Format),
+		mapEnvToConfigValue(
+			m.setters, "tracing_exporter", "TRACING_EXPORTER", src, parseEnvString, &m.config.Tracing.Exporter,
+		),
+		mapEnvToConfigValue(
+			m.setters, "tracing_endpoint", "TRACING_ENDPOINT", src, parseEnvString, &m.config.Tracing.Endpoint,
+		),
+		mapEnvToConfigValue(m.setters, "db_host", "DB_HOST", src, parseEnvString, &m.config.DB.Host),
+		mapEnvToConfigValue(m.setters, "db_port", "DB_PORT", src, parseEnvUint, &m.config.DB.Port),
+		mapEnvToConfigValue(m.setters, "db_name", "DB_NAME", src, parseEnvString, &m.config.DB.Name),
+		mapEnvToConfigValue(m.setters, "db_user", "DB_USER", src, parseEnvString, &m.config.DB.User),
+		mapEnvToConfigValue(m.setters, "db_password", "DB_PASSWORD", src, parseEnvSecret, &m.config.DB.Password),
+		mapEnvToConfigValue(m.setters, "db_auto_migrate", "DB_AUTO_MIGRATE", src, parseEnvBool, &m.config.DB.AutoMigrate),
+		mapEnvToConfigValue(m.setters, "reaper_interval", "REAPER_INTERVAL", src, parseEnvDuration, &m.config.Reaper.Interval),
+		mapEnvToConfigValue(m.setters, "reaper_batch_size", "REAPER_BATCH_SIZE", src, parseEnvUint, &m.config.Reaper.BatchSize),
+		mapEnvToConfigValue(m.setters, "reveal_secret", "REVEAL_SECRET", src, parseEnvSecret, &m.config.Reveal.Secret),
+		mapEnvToConfigValue(m.setters, "reveal_token_ttl", "REVEAL_TOKEN_TTL", src, parseEnvDuration, &m.config.Reveal.TokenTTL),
+		mapEnvToConfigValue(
+			m.setters, "lockout_max_failed_attempts", "LOCKOUT_MAX_FAILED_ATTEMPTS", src, parseEnvUint,
+			&m.config.Lockout.MaxFailedAttempts,
+		),
+		mapEnvToConfigValue(
+			m.setters, "note_min_expires_in", "NOTE_MIN_EXPIRES_IN", src, parseEnvDuration, &m.config.Note.MinExpiresIn,
+		),
+		mapEnvToConfigValue(
+			m.setters, "note_max_expires_in", "NOTE_MAX_EXPIRES_IN", src, parseEnvDuration, &m.config.Note.MaxExpiresIn,
+		),
+		mapEnvToConfigValue(m.setters, "note_max_views", "NOTE_MAX_VIEWS", src, parseEnvUint, &m.config.Note.MaxViews),
+		mapEnvToConfigValue(m.setters, "id_encoder", "ID_ENCODER", src, parseEnvString, &m.config.ID.Encoder),
+		mapEnvToConfigValue(m.setters, "id_secret", "ID_SECRET", src, parseEnvSecret, &m.config.ID.Secret),
+		mapEnvToConfigValue(m.setters, "id_legacy_max_id", "ID_LEGACY_MAX_ID", src, parseEnvUint, &m.config.ID.LegacyMaxID),
+		mapEnvToConfigValue(
+			m.setters, "id_format_alphabet", "ID_FORMAT_ALPHABET", src, parseEnvString, &m.config.IDFormat.Alphabet,
+		),
+		mapEnvToConfigValue(
+			m.setters, "id_format_min_length", "ID_FORMAT_MIN_LENGTH", src, parseEnvUint, &m.config.IDFormat.MinLength,
+		),
+		mapEnvToConfigValue(
+			m.setters, "id_format_separator", "ID_FORMAT_SEPARATOR", src, parseEnvString, &m.config.IDFormat.Separator,
+		),
+		mapEnvToConfigValue(
+			m.setters, "id_format_group_size", "ID_FORMAT_GROUP_SIZE", src, parseEnvUint, &m.config.IDFormat.GroupSize,
+		),
+		mapEnvToConfigValue(m.setters, "storage_driver", "STORAGE_DRIVER", src, parseEnvString, &m.config.Storage.Driver),
+		mapEnvToConfigValue(m.setters, "storage_path", "STORAGE_PATH", src, parseEnvString, &m.config.Storage.Path),
+	)
+}
+
+// mapEnvToConfigValue adds a setter only if the variable is set, so unset variables don't override other sources.
+func mapEnvToConfigValue[T any](
+	mp configValueSetters,
+	name, envName string,
+	src sourceType,
+	parse func(string) (T, error),
+	to *configValue[T],
+) error {
+	raw, isSet, err := lookupEnv(envName)
+	if err != nil || !isSet {
+		return err
+	}
+	value, err := parse(raw)
+	if err != nil {
+		return fmt.Errorf("env loader: invalid %s%s value: %v", envPrefix, envName, err)
+	}
+	mapToConfigValue[T](mp, name, src, &value, to)
+	return nil
 }
 
+func (m *valueMapper) mapDefaultsToConfigValues() {
+	src := DefaultSource
+	mapDefaultToConfigValue[uint64](m.setters, "port", src, 4000, &m.config.Server.Port)
+	mapDefaultToConfigValue[bool](m.setters, "expose_admin", src, false, &m.config.Server.ExposeAdmin)
+	mapDefaultToConfigValue[time.Duration](m.setters, "drain_delay", src, 0, &m.config.Server.DrainDelay)
+	mapDefaultToConfigValue[string](m.setters, "log_level", src, "info", &m.config.Log.Level)
+	mapDefaultToConfigValue[string](m.setters, "log_format", src, LogFormatText, &m.config.Log.Format)
+	mapDefaultToConfigValue[string](m.setters, "tracing_exporter", src, TracingExporterNone, &m.config.Tracing.Exporter)
+	mapDefaultToConfigValue[string](
+		m.setters, "tracing_endpoint", src, "http://localhost:4318", &m.config.Tracing.Endpoint,
+	)
+	mapDefaultToConfigValue[string](m.setters, "db_host", src, "localhost", &m.config.DB.Host)
+	mapDefaultToConfigValue[uint64](m.setters, "db_port", src, 5432, &m.config.DB.Port)
+	mapDefaultToConfigValue[bool](m.setters, "db_auto_migrate", src, false, &m.config.DB.AutoMigrate)
+	mapDefaultToConfigValue[time.Duration](m.setters, "reaper_interval", src, time.Minute, &m.config.Reaper.Interval)
+	mapDefaultToConfigValue[uint64](m.setters, "reaper_batch_size", src, 1000, &m.config.Reaper.BatchSize)
+	mapDefaultToConfigValue[time.Duration](m.setters, "reveal_token_ttl", src, 5*time.Minute, &m.config.Reveal.TokenTTL)
+	mapDefaultToConfigValue[uint64](m.setters, "lockout_max_failed_attempts", src, 10, &m.config.Lockout.MaxFailedAttempts)
+	mapDefaultToConfigValue[time.Duration](
+		m.setters, "note_min_expires_in", src, internal.DefaultMinExpiresIn, &m.config.Note.MinExpiresIn,
+	)
+	mapDefaultToConfigValue[time.Duration](
+		m.setters, "note_max_expires_in", src, internal.DefaultMaxExpiresIn, &m.config.Note.MaxExpiresIn,
+	)
+	mapDefaultToConfigValue[uint64](
+		m.setters, "note_max_views", src, uint64(internal.DefaultMaxViews), &m.config.Note.MaxViews,
+	)
+	mapDefaultToConfigValue[string](m.setters, "id_encoder", src, IDEncoderB58, &m.config.ID.Encoder)
+	mapDefaultToConfigValue[string](m.setters, "id_format_alphabet", src, encdec.B58Alphabet, &m.config.IDFormat.Alphabet)
+	mapDefaultToConfigValue[uint64](
+		m.setters, "id_format_min_length", src, encdec.DefaultIDMinLength, &m.config.IDFormat.MinLength,
+	)
+	mapDefaultToConfigValue[string](
+		m.setters, "id_format_separator", src, encdec.DefaultIDSeparator, &m.config.IDFormat.Separator,
+	)
+	mapDefaultToConfigValue[uint64](
+		m.setters, "id_format_group_size", src, encdec.DefaultIDGroupSize, &m.config.IDFormat.GroupSize,
+	)
+	mapDefaultToConfigValue[string](m.setters, "storage_driver", src, StorageDriverPostgres, &m.config.Storage.Driver)
+}
+
+func mapArgToConfigValue[T any](
+	mp configValueSetters,
+	a *args,
+	name, flagName string,
+	src sourceType,
+	from *T,
+	to *configValue[T],
+) {
+	if a.isGiven(flagName) {
+		mapToConfigValue[T](mp, name, src, from, to)
+	}
+}
+
+func mapDefaultToConfigValue[T any](mp configValueSetters, name string, src sourceType, value T, to *configValue[T]) {
+	mapToConfigValue[T](mp, name, src, &value, to)
+}
+
+// mapToConfigValue skips nil values, i.e. the ones absent in the source.
 func mapToConfigValue[T any](mp configValueSetters, name string, src sourceType, from *T, to *configValue[T]) {
+	if from == nil {
+		return
+	}
 	mp.addSetterFor(name, func() {
 		to.Set(*from, src)
 	})
@@ -0,0 +1,63 @@
+package config
+
+import (
+	"reflect"
+	"strings"
+)
+
+// reloadableConfigPaths lists the values that can be applied to a running app.
+var reloadableConfigPaths = map[string]bool{
+	"log.level":                 true,
+	"reaper.interval":           true,
+	"reaper.batchSize":          true,
+	"reveal.tokenTTL":           true,
+	"lockout.maxFailedAttempts": true,
+	"note.minExpiresIn":         true,
+	"note.maxExpiresIn":         true,
+	"note.maxViews":             true,
+}
+
+// ConfigChange is a value that differs between two configs. Secrets are kept masked.
+type ConfigChange struct {
+	Path       string
+	Old        any
+	New        any
+	Reloadable bool
+}
+
+// Diff returns the values of newCfg that differ from the ones of cfg. Config sources are not compared.
+func (cfg *Config) Diff(newCfg *Config) []ConfigChange {
+	var changes []ConfigChange
+	oldCfgValue, newCfgValue := reflect.ValueOf(cfg).Elem(), reflect.ValueOf(newCfg).Elem()
+	cfgType := oldCfgValue.Type()
+	for i := 0; i < cfgType.NumField(); i++ {
+		section := cfgType.Field(i)
+		if section.Type.Kind() != reflect.Struct || section.Type == reflect.TypeOf(ConfigSource{}) {
+			continue
+		}
+
+		for j := 0; j < section.Type.NumField(); j++ {
+			oldValue := oldCfgValue.Field(i).Field(j).FieldByName("Value").Interface()
+			newValue := newCfgValue.Field(i).Field(j).FieldByName("Value").Interface()
+			if reflect.DeepEqual(oldValue, newValue) {
+				continue
+			}
+			path := yamlFieldName(section) + "." + yamlFieldName(section.Type.Field(j))
+			changes = append(changes, ConfigChange{
+				Path:       path,
+				Old:        oldValue,
+				New:        newValue,
+				Reloadable: reloadableConfigPaths[path],
+			})
+		}
+	}
+	return changes
+}
+
+func yamlFieldName(field reflect.StructField) string {
+	name, _, _ := strings.Cut(field.Tag.Get("yaml"), ",")
+	if name == "" {
+		return strings.ToLower(field.Name)
+	}
+	return name
+}
@@ -0,0 +1,55 @@
+package config
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	envPrefix     = "SNOTE_"
+	envFileSuffix = "_FILE"
+)
+
+// lookupEnv returns the value of the SNOTE_-prefixed variable. If the variable is set with the _FILE suffix instead,
+// the value is read from the file it points to, e.g. a Docker or Kubernetes secret mount.
+func lookupEnv(name string) (string, bool, error) {
+	key := envPrefix + name
+	fileKey := key + envFileSuffix
+	value, isSet := os.LookupEnv(key)
+	path, isFileSet := os.LookupEnv(fileKey)
+	if isSet && isFileSet {
+		return "", false, fmt.Errorf("env loader: both %s and %s are set", key, fileKey)
+	}
+	if !isFileSet {
+		return value, isSet, nil
+	}
+
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return "", false, fmt.Errorf("env loader: %s: %v", fileKey, err)
+	}
+	return strings.TrimRight(string(content), "\r\n"), true, nil
+}
+
+func parseEnvString(s string) (string, error) {
+	return s, nil
+}
+
+func parseEnvSecret(s string) (secretString, error) {
+	return secretString{value: s}, nil
+}
+
+func parseEnvUint(s string) (uint64, error) {
+	return strconv.ParseUint(s, 10, 64)
+}
+
+func parseEnvBool(s string) (bool, error) {
+	return strconv.ParseBool(s)
+}
+
+func parseEnvDuration(s string) (time.Duration, error) {
+	return time.ParseDuration(s)
+}
@@ -3,48 +3,156 @@ package config
 import (
 	"bufio"
 	"fmt"
+	"github.com/ledorub/snote-api/internal/encdec"
 	"os"
+	"path/filepath"
+	"strings"
+	"time"
 )
 
+const (
+	ConfigFormatYAML = "yaml"
+	ConfigFormatJSON = "json"
+	ConfigFormatTOML = "toml"
+)
+
+// configFile fields are pointers, so that absent keys are told apart from zero values and don't override defaults.
 type configFile struct {
-	Server configFileServer
-	DB     configFileDB
+	Server   configFileServer   `yaml:"server" json:"server" toml:"server"`
+	Log      configFileLog      `yaml:"log" json:"log" toml:"log"`
+	Tracing  configFileTracing  `yaml:"tracing" json:"tracing" toml:"tracing"`
+	DB       configFileDB       `yaml:"db" json:"db" toml:"db"`
+	Reaper   configFileReaper   `yaml:"reaper" json:"reaper" toml:"reaper"`
+	Reveal   configFileReveal   `yaml:"reveal" json:"reveal" toml:"reveal"`
+	Lockout  configFileLockout  `yaml:"lockout" json:"lockout" toml:"lockout"`
+	Note     configFileNote     `yaml:"note" json:"note" toml:"note"`
+	ID       configFileID       `yaml:"id" json:"id" toml:"id"`
+	IDFormat configFileIDFormat `yaml:"idFormat" json:"idFormat" toml:"idFormat"`
+	Storage  configFileStorage  `yaml:"storage" json:"storage" toml:"storage"`
 }
 
 type configFileServer struct {
-	Port uint64 `yaml:"port"`
+	Port        *uint64   `yaml:"port" json:"port" toml:"port"`
+	AdminPort   *uint64   `yaml:"adminPort" json:"adminPort" toml:"adminPort"`
+	ExposeAdmin *bool     `yaml:"exposeAdmin" json:"exposeAdmin" toml:"exposeAdmin"`
+	DrainDelay  *duration `yaml:"drainDelay" json:"drainDelay" toml:"drainDelay"`
+}
+
+type configFileLog struct {
+	Level  *string `yaml:"level" json:"level" toml:"level"`
+	Format *string `yaml:"format" json:"format" toml:"format"`
+}
+
+type configFileTracing struct {
+	Exporter *string `yaml:"exporter" json:"exporter" toml:"exporter"`
+	Endpoint *string `yaml:"endpoint" json:"endpoint" toml:"endpoint"`
 }
 
 type configFileDB struct {
-	Host     string       `yaml:"host"`
-	Port     uint64       `yaml:"port"`
-	Name     string       `yaml:"name"`
-	User     string       `yaml:"user"`
-	Password secretString `yaml:"password"`
+	Host        *string       `yaml:"host" json:"host" toml:"host"`
+	Port        *uint64       `yaml:"port" json:"port" toml:"port"`
+	Name        *string       `yaml:"name" json:"name" toml:"name"`
+	User        *string       `yaml:"user" json:"user" toml:"user"`
+	Password    *secretString `yaml:"password" json:"password" toml:"password"`
+	AutoMigrate *bool         `yaml:"autoMigrate" json:"autoMigrate" toml:"autoMigrate"`
+}
+
+type configFileReaper struct {
+	Interval  *duration `yaml:"interval" json:"interval" toml:"interval"`
+	BatchSize *uint64   `yaml:"batchSize" json:"batchSize" toml:"batchSize"`
+}
+
+type configFileReveal struct {
+	Secret   *secretString `yaml:"secret" json:"secret" toml:"secret"`
+	TokenTTL *duration     `yaml:"tokenTTL" json:"tokenTTL" toml:"tokenTTL"`
+}
+
+type configFileLockout struct {
+	MaxFailedAttempts *uint64 `yaml:"maxFailedAttempts" json:"maxFailedAttempts" toml:"maxFailedAttempts"`
+}
+
+type configFileNote struct {
+	MinExpiresIn *duration `yaml:"minExpiresIn" json:"minExpiresIn" toml:"minExpiresIn"`
+	MaxExpiresIn *duration `yaml:"maxExpiresIn" json:"maxExpiresIn" toml:"maxExpiresIn"`
+	MaxViews     *uint64   `yaml:"maxViews" json:"maxViews" toml:"maxViews"`
+}
+
+type configFileID struct {
+	Encoder     *string       `yaml:"encoder" json:"encoder" toml:"encoder"`
+	Secret      *secretString `yaml:"secret" json:"secret" toml:"secret"`
+	LegacyMaxID *uint64       `yaml:"legacyMaxID" json:"legacyMaxID" toml:"legacyMaxID"`
+}
+
+type configFileIDFormat struct {
+	Alphabet  *string `yaml:"alphabet" json:"alphabet" toml:"alphabet"`
+	MinLength *uint64 `yaml:"minLength" json:"minLength" toml:"minLength"`
+	Separator *string `yaml:"separator" json:"separator" toml:"separator"`
+	GroupSize *uint64 `yaml:"groupSize" json:"groupSize" toml:"groupSize"`
+}
+
+type configFileStorage struct {
+	Driver *string `yaml:"driver" json:"driver" toml:"driver"`
+	Path   *string `yaml:"path" json:"path" toml:"path"`
+}
+
+// duration is read from config files as a string, e.g. 1m30s, whatever the file format is.
+type duration time.Duration
+
+func (d *duration) UnmarshalText(text []byte) error {
+	parsed, err := time.ParseDuration(string(text))
+	if err != nil {
+		return err
+	}
+	*d = duration(parsed)
+	return nil
+}
+
+// newConfigFileDecoder returns the decoder of the format. If no format is given, it is taken from the extension
+// of the file, falling back to YAML for unknown extensions.
+func newConfigFileDecoder(format, path string) (configFileDecoder, error) {
+	isFormatGiven := format != ""
+	if !isFormatGiven {
+		format = strings.ToLower(strings.TrimPrefix(filepath.Ext(path), "."))
+	}
+	switch format {
+	case ConfigFormatJSON:
+		return encdec.NewJSONDecoder(), nil
+	case ConfigFormatTOML:
+		return encdec.NewTOMLDecoder(), nil
+	case ConfigFormatYAML, "yml":
+		return encdec.NewYAMLDecoder(), nil
+	}
+	if isFormatGiven {
+		return nil, fmt.Errorf(
+			"config file loader: unsupported format %q. Should be one of %q, %q or %q",
+			format, ConfigFormatYAML, ConfigFormatJSON, ConfigFormatTOML,
+		)
+	}
+	return encdec.NewYAMLDecoder(), nil
 }
 
 func (l *Loader) loadFile() (*configFile, error) {
-	reader, err := getFileReader(l.configFile)
+	f, err := openFile(l.configFile)
 	if err != nil {
 		return nil, fmt.Errorf("config file loader: %v", err)
 	}
+	defer f.Close()
+
+	decoder := l.configFileDecoder
+	if decoder == nil {
+		if decoder, err = newConfigFileDecoder(l.configFormat, l.configFile); err != nil {
+			return nil, err
+		}
+	}
 
 	fileConfig := &configFile{}
-	err = l.configFileDecoder.Decode(reader, fileConfig)
+	err = decoder.Decode(bufio.NewReader(f), fileConfig)
 	if err != nil {
 		return nil, fmt.Errorf("config file loader: %v", err)
 	}
 	return fileConfig, nil
 }
 
-func getFileReader(path string) (*bufio.Reader, error) {
-	f, err := openFile(path)
-	if err != nil {
-		return nil, err
-	}
-	return bufio.NewReader(f), nil
-}
-
 func openFile(path string) (*os.File, error) {
 	return os.Open(path)
 }
@@ -26,3 +26,19 @@ func (s secretString) MarshalYAML() (any, error) {
 func (s *secretString) UnmarshalYAML(unmarshal func(any) error) error {
 	return unmarshal(&s.value)
 }
+
+// Set allows passing secrets as command line flags.
+func (s *secretString) Set(value string) error {
+	s.value = value
+	return nil
+}
+
+func (s secretString) MarshalText() ([]byte, error) {
+	return []byte(s.String()), nil
+}
+
+// UnmarshalText allows reading secrets from JSON and TOML config files.
+func (s *secretString) UnmarshalText(text []byte) error {
+	s.value = string(text)
+	return nil
+}
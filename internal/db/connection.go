@@ -4,15 +4,18 @@ import (
 	"context"
 	"fmt"
 	"github.com/jackc/pgx/v5/pgxpool"
+	"go.opentelemetry.io/otel/trace"
 	"net/url"
 	"time"
 )
 
-func CreatePool(ctx context.Context, dsn string) (*pgxpool.Pool, error) {
+// CreatePool connects to the DB. Queries are traced with spans of tracerProvider.
+func CreatePool(ctx context.Context, dsn string, tracerProvider trace.TracerProvider) (*pgxpool.Pool, error) {
 	config, err := pgxpool.ParseConfig(dsn)
 	if err != nil {
 		return nil, fmt.Errorf("DSN parsing: %w", err)
 	}
+	config.ConnConfig.Tracer = newQueryTracer(tracerProvider)
 	pool, err := pgxpool.NewWithConfig(ctx, config)
 	if err != nil {
 		return nil, fmt.Errorf("connection pool: %w", err)
@@ -0,0 +1,168 @@
+// Package memory provides an in-memory note storage for local development and tests.
+// Notes do not survive a restart. Unlike the SQL storages, it evicts expired notes on its own: they are dropped
+// when they are accessed, and Create sweeps out the rest every sweepInterval. DeleteExpired, which the reaper calls,
+// removes them as well.
+package memory
+
+import (
+	"bytes"
+	"context"
+	"github.com/ledorub/snote-api/internal"
+	"log/slog"
+	"sync"
+	"time"
+)
+
+var ErrNotFound = internal.ErrNoteNotFound
+
+// sweepInterval is how often Create sweeps out expired notes, so that notes nobody asks for again do not pile up
+// when the reaper is not running.
+const sweepInterval = time.Minute
+
+type noteEntry struct {
+	note           internal.NoteModel
+	failedAttempts uint
+}
+
+type NoteRepository struct {
+	logger *slog.Logger
+	mu     sync.Mutex
+	notes  map[uint64]*noteEntry
+	lastID uint64
+	// lastSweep is when Create last swept out expired notes.
+	lastSweep time.Time
+	now       func() time.Time
+}
+
+func NewNoteRepository(logger *slog.Logger) *NoteRepository {
+	return &NoteRepository{logger: logger, notes: make(map[uint64]*noteEntry), now: time.Now}
+}
+
+func (r *NoteRepository) Create(ctx context.Context, note *internal.NoteModel) (*internal.NoteModel, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if now := r.now(); now.Sub(r.lastSweep) >= sweepInterval {
+		r.sweep(now)
+		r.lastSweep = now
+	}
+	r.lastID++
+	note.ID = r.lastID
+	r.notes[note.ID] = &noteEntry{note: copyNote(note)}
+	return note, nil
+}
+
+func (r *NoteRepository) Get(ctx context.Context, id uint64) (*internal.NoteModel, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	entry, exists := r.lookup(id)
+	if !exists {
+		return nil, ErrNotFound
+	}
+	note := copyNote(&entry.note)
+	return &note, nil
+}
+
+// View decrements the remaining views of the note matching id and keyHash and returns it.
+// Nothing is taken if the note has no longer got viewsRemaining views. The note is deleted once no views remain.
+// internal.ErrNoteNotFound is returned if no note matches.
+// Like the other storages, it expects callers to have compared keyHash in constant time beforehand.
+func (r *NoteRepository) View(
+	ctx context.Context,
+	id uint64,
+	keyHash []byte,
+	viewsRemaining uint,
+) (*internal.NoteModel, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	entry, exists := r.lookup(id)
+	if !exists || !bytes.Equal(entry.note.KeyHash, keyHash) ||
+		entry.note.ViewsRemaining != viewsRemaining || entry.note.ViewsRemaining == 0 {
+		return nil, ErrNotFound
+	}
+	entry.note.ViewsRemaining--
+	if entry.note.ViewsRemaining == 0 {
+		delete(r.notes, id)
+	}
+	note := copyNote(&entry.note)
+	return &note, nil
+}
+
+func (r *NoteRepository) Delete(ctx context.Context, id uint64) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	delete(r.notes, id)
+	return nil
+}
+
+// RecordFailedAttempt increments the number of wrong key hashes presented for the note and returns it.
+// internal.ErrNoteNotFound is returned if the note does not exist.
+func (r *NoteRepository) RecordFailedAttempt(ctx context.Context, id uint64) (uint, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	entry, exists := r.lookup(id)
+	if !exists {
+		return 0, ErrNotFound
+	}
+	entry.failedAttempts++
+	return entry.failedAttempts, nil
+}
+
+func (r *NoteRepository) DeleteExpired(ctx context.Context, before time.Time, limit uint64) (uint64, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	var deleted uint64
+	for id, entry := range r.notes {
+		if deleted == limit {
+			break
+		}
+		if isExpired(entry, before) || entry.note.ViewsRemaining == 0 {
+			delete(r.notes, id)
+			deleted++
+		}
+	}
+	return deleted, nil
+}
+
+// lookup returns the entry of the note with the given ID. An expired note is evicted instead. r.mu must be held.
+func (r *NoteRepository) lookup(id uint64) (*noteEntry, bool) {
+	entry, exists := r.notes[id]
+	if exists && isExpired(entry, r.now()) {
+		delete(r.notes, id)
+		return nil, false
+	}
+	return entry, exists
+}
+
+// sweep evicts every note which has expired by now. r.mu must be held.
+func (r *NoteRepository) sweep(now time.Time) {
+	var evicted int
+	for id, entry := range r.notes {
+		if isExpired(entry, now) {
+			delete(r.notes, id)
+			evicted++
+		}
+	}
+	if evicted > 0 {
+		r.logger.Debug("note repository: evicted expired notes", "count", evicted)
+	}
+}
+
+func isExpired(entry *noteEntry, now time.Time) bool {
+	return !entry.note.ExpiresAt.After(now)
+}
+
+func copyNote(note *internal.NoteModel) internal.NoteModel {
+	noteCopy := *note
+	if note.Content != nil {
+		content := *note.Content
+		noteCopy.Content = &content
+	}
+	noteCopy.KeyHash = bytes.Clone(note.KeyHash)
+	return noteCopy
+}
@@ -0,0 +1,100 @@
+package memory
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"github.com/ledorub/snote-api/internal"
+	"github.com/ledorub/snote-api/internal/db/notetest"
+	"io"
+	"log/slog"
+	"testing"
+	"time"
+)
+
+func newTestNoteRepository() *NoteRepository {
+	return NewNoteRepository(slog.New(slog.NewTextHandler(io.Discard, nil)))
+}
+
+func TestNoteRepository(t *testing.T) {
+	notetest.Run(t, func(t *testing.T) notetest.NoteRepository {
+		return newTestNoteRepository()
+	})
+}
+
+func createTestNote(t *testing.T, repo *NoteRepository, expiresAt time.Time) *internal.NoteModel {
+	t.Helper()
+	content := "content"
+	note, err := repo.Create(context.Background(), &internal.NoteModel{
+		Content:           &content,
+		CreatedAt:         expiresAt.Add(-time.Hour),
+		ExpiresAt:         expiresAt,
+		ExpiresAtTimeZone: "UTC",
+		KeyHash:           bytes.Repeat([]byte{0xff}, 32),
+		ViewsRemaining:    2,
+	})
+	if err != nil {
+		t.Fatalf("Create() error = %v", err)
+	}
+	return note
+}
+
+func TestNoteRepositoryEvictsExpiredNotesOnAccess(t *testing.T) {
+	tests := []struct {
+		name   string
+		access func(repo *NoteRepository, note *internal.NoteModel) error
+	}{
+		{
+			name: "get",
+			access: func(repo *NoteRepository, note *internal.NoteModel) error {
+				_, err := repo.Get(context.Background(), note.ID)
+				return err
+			},
+		},
+		{
+			name: "view",
+			access: func(repo *NoteRepository, note *internal.NoteModel) error {
+				_, err := repo.View(context.Background(), note.ID, note.KeyHash, note.ViewsRemaining)
+				return err
+			},
+		},
+		{
+			name: "record failed attempt",
+			access: func(repo *NoteRepository, note *internal.NoteModel) error {
+				_, err := repo.RecordFailedAttempt(context.Background(), note.ID)
+				return err
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			repo := newTestNoteRepository()
+			note := createTestNote(t, repo, time.Now().Add(-time.Second))
+
+			if err := tt.access(repo, note); !errors.Is(err, ErrNotFound) {
+				t.Errorf("error = %v, want %v", err, ErrNotFound)
+			}
+			if len(repo.notes) != 0 {
+				t.Errorf("%d notes kept, want expired note evicted", len(repo.notes))
+			}
+		})
+	}
+}
+
+func TestNoteRepositorySweepsExpiredNotes(t *testing.T) {
+	now := time.Now()
+	repo := newTestNoteRepository()
+	repo.now = func() time.Time { return now }
+
+	expired := createTestNote(t, repo, now.Add(time.Second))
+	live := createTestNote(t, repo, now.Add(time.Hour))
+
+	now = now.Add(sweepInterval)
+	createTestNote(t, repo, now.Add(time.Hour))
+	if _, exists := repo.notes[expired.ID]; exists {
+		t.Error("Create() kept an expired note past the sweep interval")
+	}
+	if _, exists := repo.notes[live.ID]; !exists {
+		t.Error("Create() evicted a note which has not expired")
+	}
+}
@@ -0,0 +1,48 @@
+package db
+
+import (
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/ledorub/snote-api/internal/metrics"
+)
+
+type poolMetric struct {
+	name, help string
+	value      func(stat *pgxpool.Stat) float64
+}
+
+// RegisterPoolMetrics exposes the stats of the pool, which are read on every scrape.
+func RegisterPoolMetrics(registry *metrics.Registry, pool *pgxpool.Pool) {
+	gauges := []poolMetric{
+		{"snote_db_pool_acquired_conns", "Number of connections currently in use.",
+			func(stat *pgxpool.Stat) float64 { return float64(stat.AcquiredConns()) }},
+		{"snote_db_pool_idle_conns", "Number of idle connections.",
+			func(stat *pgxpool.Stat) float64 { return float64(stat.IdleConns()) }},
+		{"snote_db_pool_constructing_conns", "Number of connections being established.",
+			func(stat *pgxpool.Stat) float64 { return float64(stat.ConstructingConns()) }},
+		{"snote_db_pool_total_conns", "Number of connections in the pool.",
+			func(stat *pgxpool.Stat) float64 { return float64(stat.TotalConns()) }},
+		{"snote_db_pool_max_conns", "Max number of connections in the pool.",
+			func(stat *pgxpool.Stat) float64 { return float64(stat.MaxConns()) }},
+	}
+	counters := []poolMetric{
+		{"snote_db_pool_acquires_total", "Number of successful connection acquires.",
+			func(stat *pgxpool.Stat) float64 { return float64(stat.AcquireCount()) }},
+		{"snote_db_pool_canceled_acquires_total", "Number of connection acquires canceled by a context.",
+			func(stat *pgxpool.Stat) float64 { return float64(stat.CanceledAcquireCount()) }},
+		{"snote_db_pool_empty_acquires_total", "Number of connection acquires that had to wait for a connection.",
+			func(stat *pgxpool.Stat) float64 { return float64(stat.EmptyAcquireCount()) }},
+		{"snote_db_pool_acquire_duration_seconds_total", "Time spent acquiring connections.",
+			func(stat *pgxpool.Stat) float64 { return stat.AcquireDuration().Seconds() }},
+		{"snote_db_pool_new_conns_total", "Number of connections established.",
+			func(stat *pgxpool.Stat) float64 { return float64(stat.NewConnsCount()) }},
+	}
+
+	for _, gauge := range gauges {
+		value := gauge.value
+		registry.NewGaugeFunc(gauge.name, gauge.help, func() float64 { return value(pool.Stat()) })
+	}
+	for _, counter := range counters {
+		value := counter.value
+		registry.NewCounterFunc(counter.name, counter.help, func() float64 { return value(pool.Stat()) })
+	}
+}
@@ -0,0 +1,125 @@
+package db
+
+import (
+	"embed"
+	"errors"
+	"fmt"
+	"github.com/golang-migrate/migrate/v4"
+	_ "github.com/golang-migrate/migrate/v4/database/pgx/v5"
+	"github.com/golang-migrate/migrate/v4/source/iofs"
+	"io/fs"
+	"log/slog"
+	"net/url"
+	"strings"
+)
+
+//go:embed migrations/*.sql
+var migrationsFS embed.FS
+
+// Migrator applies the embedded migrations. Every operation holds a Postgres advisory lock,
+// so several instances may run it against the same database at once.
+type Migrator struct {
+	logger  *slog.Logger
+	migrate *migrate.Migrate
+}
+
+type MigrationStatus struct {
+	Version       uint
+	LatestVersion uint
+	Dirty         bool
+}
+
+func NewMigrator(logger *slog.Logger, dsn string) (*Migrator, error) {
+	src, err := iofs.New(migrationsFS, "migrations")
+	if err != nil {
+		return nil, fmt.Errorf("migrations source: %w", err)
+	}
+	dbURL, err := url.Parse(dsn)
+	if err != nil {
+		return nil, fmt.Errorf("DSN parsing: %w", err)
+	}
+	dbURL.Scheme = "pgx5"
+
+	m, err := migrate.NewWithSourceInstance("iofs", src, dbURL.String())
+	if err != nil {
+		return nil, fmt.Errorf("migrator: %w", err)
+	}
+	m.Log = &migrateLogger{logger: logger}
+	return &Migrator{logger: logger, migrate: m}, nil
+}
+
+// Up applies all pending migrations.
+func (m *Migrator) Up() error {
+	if err := m.migrate.Up(); err != nil && !errors.Is(err, migrate.ErrNoChange) {
+		return fmt.Errorf("migration up: %w", err)
+	}
+	return nil
+}
+
+// Down reverts the given number of the most recent migrations.
+func (m *Migrator) Down(steps uint) error {
+	if err := m.migrate.Steps(-int(steps)); err != nil && !errors.Is(err, migrate.ErrNoChange) {
+		return fmt.Errorf("migration down: %w", err)
+	}
+	return nil
+}
+
+// Force sets the migration version without running any migration and clears the dirty flag.
+func (m *Migrator) Force(version uint) error {
+	if err := m.migrate.Force(int(version)); err != nil {
+		return fmt.Errorf("migration force: %w", err)
+	}
+	return nil
+}
+
+func (m *Migrator) Status() (*MigrationStatus, error) {
+	version, dirty, err := m.migrate.Version()
+	if err != nil && !errors.Is(err, migrate.ErrNilVersion) {
+		return nil, fmt.Errorf("migration status: %w", err)
+	}
+	latest, err := latestMigrationVersion()
+	if err != nil {
+		return nil, fmt.Errorf("migration status: %w", err)
+	}
+	return &MigrationStatus{Version: version, LatestVersion: latest, Dirty: dirty}, nil
+}
+
+func (m *Migrator) Close() error {
+	srcErr, dbErr := m.migrate.Close()
+	return errors.Join(srcErr, dbErr)
+}
+
+func latestMigrationVersion() (uint, error) {
+	src, err := iofs.New(migrationsFS, "migrations")
+	if err != nil {
+		return 0, err
+	}
+	defer src.Close()
+
+	version, err := src.First()
+	if err != nil {
+		return 0, err
+	}
+	for {
+		next, err := src.Next(version)
+		if errors.Is(err, fs.ErrNotExist) {
+			return version, nil
+		}
+		if err != nil {
+			return 0, err
+		}
+		version = next
+	}
+}
+
+type migrateLogger struct {
+	logger *slog.Logger
+}
+
+func (l *migrateLogger) Printf(format string, v ...any) {
+	l.logger.Info("migrate: " + strings.TrimSpace(fmt.Sprintf(format, v...)))
+}
+
+func (l *migrateLogger) Verbose() bool {
+	return false
+}
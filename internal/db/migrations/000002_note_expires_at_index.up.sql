@@ -0,0 +1 @@
+CREATE INDEX idx_note_expires_at ON note (expires_at);
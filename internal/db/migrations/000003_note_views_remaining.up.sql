@@ -0,0 +1,3 @@
+ALTER TABLE note
+    ADD COLUMN views_remaining INTEGER NOT NULL DEFAULT 1
+    CONSTRAINT chk_views_remaining_non_negative CHECK (views_remaining >= 0);
@@ -0,0 +1 @@
+ALTER TABLE note DROP COLUMN failed_attempts;
@@ -0,0 +1,2 @@
+ALTER TABLE note
+    ADD COLUMN failed_attempts INTEGER NOT NULL DEFAULT 0;
@@ -15,4 +15,6 @@ type Note struct {
 	ExpiresAt         pgtype.Timestamp
 	ExpiresAtTimezone string
 	KeyHash           []byte
+	ViewsRemaining    int32
+	FailedAttempts    int32
 }
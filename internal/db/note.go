@@ -2,27 +2,35 @@ package db
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"github.com/jackc/pgx/v5"
 	"github.com/ledorub/snote-api/internal"
-	"log"
+	"log/slog"
+	"math"
+	"time"
 )
 
 type NoteRepository struct {
-	logger  *log.Logger
+	logger  *slog.Logger
 	queries *Queries
 }
 
-func NewNoteRepository(logger *log.Logger, queries *Queries) *NoteRepository {
+func NewNoteRepository(logger *slog.Logger, queries *Queries) *NoteRepository {
 	return &NoteRepository{logger: logger, queries: queries}
 }
 
 func (r *NoteRepository) Create(ctx context.Context, note *internal.NoteModel) (*internal.NoteModel, error) {
+	if note.ViewsRemaining > math.MaxInt32 {
+		return &internal.NoteModel{}, ErrIntOverflow
+	}
 	createdNote, err := r.queries.CreateNote(ctx, CreateNoteParams{
 		Content:           *note.Content,
 		CreatedAt:         newTimestampTZ(note.CreatedAt),
 		ExpiresAt:         newTimestamp(note.ExpiresAt),
 		ExpiresAtTimezone: note.ExpiresAtTimeZone,
 		KeyHash:           note.KeyHash,
+		ViewsRemaining:    int32(note.ViewsRemaining),
 	})
 	if err != nil {
 		return &internal.NoteModel{}, fmt.Errorf("creation failed: %w", err)
@@ -34,26 +42,61 @@ func (r *NoteRepository) Create(ctx context.Context, note *internal.NoteModel) (
 	note.ExpiresAt = createdNote.ExpiresAt.Time
 	note.ExpiresAtTimeZone = createdNote.ExpiresAtTimezone
 	note.KeyHash = createdNote.KeyHash
+	note.ViewsRemaining = uint(createdNote.ViewsRemaining)
 	return note, nil
 }
 
+// Get returns internal.ErrNoteNotFound for IDs which are not stored, including those which could never be.
 func (r *NoteRepository) Get(ctx context.Context, id uint64) (*internal.NoteModel, error) {
 	pgId, err := uInt64ToPgInt8(id)
 	if err != nil {
-		return nil, err
+		return nil, internal.ErrNoteNotFound
 	}
 	note, err := r.queries.GetNote(ctx, pgId)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, internal.ErrNoteNotFound
+	}
 	if err != nil {
 		return nil, fmt.Errorf("retrieving failed: %w", err)
 	}
-	return &internal.NoteModel{
-		ID:                pgIntToUInt64(note.ID),
-		Content:           &note.Content,
-		CreatedAt:         note.CreatedAt.Time,
-		ExpiresAt:         note.ExpiresAt.Time,
-		ExpiresAtTimeZone: note.ExpiresAtTimezone,
-		KeyHash:           note.KeyHash,
-	}, nil
+	return noteToModel(note), nil
+}
+
+// View atomically decrements the remaining views of the note matching id and keyHash and returns it.
+// Nothing is taken if the note has no longer got viewsRemaining views, i.e. another reader has viewed it since
+// the caller looked it up. The last view deletes the note in the same statement, so it is never handed out
+// more times than allowed and its content is gone as soon as it has been read.
+// internal.ErrNoteNotFound is returned if no note matches.
+// keyHash is not compared in constant time. It only keeps a note from being burnt with a key hash nobody has
+// checked, so callers compare it in constant time beforehand.
+func (r *NoteRepository) View(
+	ctx context.Context,
+	id uint64,
+	keyHash []byte,
+	viewsRemaining uint,
+) (*internal.NoteModel, error) {
+	pgId, err := uInt64ToPgInt8(id)
+	if err != nil || viewsRemaining > math.MaxInt32 {
+		return nil, internal.ErrNoteNotFound
+	}
+	var note Note
+	if viewsRemaining == 1 {
+		note, err = r.queries.BurnNote(ctx, BurnNoteParams{ID: pgId, KeyHash: keyHash})
+		note.ViewsRemaining = 0
+	} else {
+		note, err = r.queries.ViewNote(ctx, ViewNoteParams{
+			ID:             pgId,
+			KeyHash:        keyHash,
+			ViewsRemaining: int32(viewsRemaining),
+		})
+	}
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, internal.ErrNoteNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("viewing failed: %w", err)
+	}
+	return noteToModel(note), nil
 }
 
 func (r *NoteRepository) Delete(ctx context.Context, id uint64) error {
@@ -66,3 +109,46 @@ func (r *NoteRepository) Delete(ctx context.Context, id uint64) error {
 	}
 	return nil
 }
+
+// RecordFailedAttempt increments the number of wrong key hashes presented for the note and returns it.
+// internal.ErrNoteNotFound is returned if the note does not exist.
+func (r *NoteRepository) RecordFailedAttempt(ctx context.Context, id uint64) (uint, error) {
+	pgId, err := uInt64ToPgInt8(id)
+	if err != nil {
+		return 0, err
+	}
+	attempts, err := r.queries.RecordFailedAttempt(ctx, pgId)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return 0, internal.ErrNoteNotFound
+	}
+	if err != nil {
+		return 0, fmt.Errorf("failed attempt recording failed: %w", err)
+	}
+	return uint(attempts), nil
+}
+
+func (r *NoteRepository) DeleteExpired(ctx context.Context, before time.Time, limit uint64) (uint64, error) {
+	if limit > math.MaxInt32 {
+		return 0, ErrIntOverflow
+	}
+	deleted, err := r.queries.DeleteExpiredNotes(ctx, DeleteExpiredNotesParams{
+		ExpiresAt: newTimestamp(before.UTC()),
+		Limit:     int32(limit),
+	})
+	if err != nil {
+		return 0, fmt.Errorf("expired notes deletion failed: %w", err)
+	}
+	return uint64(deleted), nil
+}
+
+func noteToModel(note Note) *internal.NoteModel {
+	return &internal.NoteModel{
+		ID:                pgIntToUInt64(note.ID),
+		Content:           &note.Content,
+		CreatedAt:         note.CreatedAt.Time,
+		ExpiresAt:         note.ExpiresAt.Time,
+		ExpiresAtTimeZone: note.ExpiresAtTimezone,
+		KeyHash:           note.KeyHash,
+		ViewsRemaining:    uint(note.ViewsRemaining),
+	}
+}
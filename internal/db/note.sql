@@ -5,11 +5,39 @@ WHERE id = $1;
 
 -- name: CreateNote :one
 INSERT INTO note (
-    content, created_at, expires_at, expires_at_timezone, key_hash
+    content, created_at, expires_at, expires_at_timezone, key_hash, views_remaining
 ) VALUES (
-    $1, $2, $3, $4, $5
+    $1, $2, $3, $4, $5, $6
 ) RETURNING *;
 
 -- name: DeleteNote :exec
 DELETE FROM note
 WHERE id = $1;
+
+-- name: ViewNote :one
+UPDATE note
+SET views_remaining = views_remaining - 1
+WHERE id = $1 AND key_hash = $2 AND views_remaining = $3 AND views_remaining > 1
+RETURNING *;
+
+-- name: BurnNote :one
+DELETE FROM note
+WHERE id = $1 AND key_hash = $2 AND views_remaining = 1
+RETURNING *;
+
+-- name: RecordFailedAttempt :one
+UPDATE note
+SET failed_attempts = failed_attempts + 1
+WHERE id = $1
+RETURNING failed_attempts;
+
+-- name: DeleteExpiredNotes :execrows
+DELETE FROM note
+WHERE id IN (
+    SELECT expired.id
+    FROM note AS expired
+    WHERE expired.expires_at <= $1 OR expired.views_remaining = 0
+    ORDER BY expired.expires_at
+    LIMIT $2
+    FOR UPDATE SKIP LOCKED
+);
@@ -11,12 +11,39 @@ import (
 	"github.com/jackc/pgx/v5/pgtype"
 )
 
+const burnNote = `-- name: BurnNote :one
+DELETE FROM note
+WHERE id = $1 AND key_hash = $2 AND views_remaining = 1
+RETURNING id, content, created_at, expires_at, expires_at_timezone, key_hash, views_remaining, failed_attempts
+`
+
+type BurnNoteParams struct {
+	ID      pgtype.Int8
+	KeyHash []byte
+}
+
+func (q *Queries) BurnNote(ctx context.Context, arg BurnNoteParams) (Note, error) {
+	row := q.db.QueryRow(ctx, burnNote, arg.ID, arg.KeyHash)
+	var i Note
+	err := row.Scan(
+		&i.ID,
+		&i.Content,
+		&i.CreatedAt,
+		&i.ExpiresAt,
+		&i.ExpiresAtTimezone,
+		&i.KeyHash,
+		&i.ViewsRemaining,
+		&i.FailedAttempts,
+	)
+	return i, err
+}
+
 const createNote = `-- name: CreateNote :one
 INSERT INTO note (
-    content, created_at, expires_at, expires_at_timezone, key_hash
+    content, created_at, expires_at, expires_at_timezone, key_hash, views_remaining
 ) VALUES (
-    $1, $2, $3, $4, $5
-) RETURNING id, content, created_at, expires_at, expires_at_timezone, key_hash
+    $1, $2, $3, $4, $5, $6
+) RETURNING id, content, created_at, expires_at, expires_at_timezone, key_hash, views_remaining, failed_attempts
 `
 
 type CreateNoteParams struct {
@@ -25,6 +52,7 @@ type CreateNoteParams struct {
 	ExpiresAt         pgtype.Timestamp
 	ExpiresAtTimezone string
 	KeyHash           []byte
+	ViewsRemaining    int32
 }
 
 func (q *Queries) CreateNote(ctx context.Context, arg CreateNoteParams) (Note, error) {
@@ -34,6 +62,7 @@ func (q *Queries) CreateNote(ctx context.Context, arg CreateNoteParams) (Note, e
 		arg.ExpiresAt,
 		arg.ExpiresAtTimezone,
 		arg.KeyHash,
+		arg.ViewsRemaining,
 	)
 	var i Note
 	err := row.Scan(
@@ -43,10 +72,37 @@ func (q *Queries) CreateNote(ctx context.Context, arg CreateNoteParams) (Note, e
 		&i.ExpiresAt,
 		&i.ExpiresAtTimezone,
 		&i.KeyHash,
+		&i.ViewsRemaining,
+		&i.FailedAttempts,
 	)
 	return i, err
 }
 
+const deleteExpiredNotes = `-- name: DeleteExpiredNotes :execrows
+DELETE FROM note
+WHERE id IN (
+    SELECT expired.id
+    FROM note AS expired
+    WHERE expired.expires_at <= $1 OR expired.views_remaining = 0
+    ORDER BY expired.expires_at
+    LIMIT $2
+    FOR UPDATE SKIP LOCKED
+)
+`
+
+type DeleteExpiredNotesParams struct {
+	ExpiresAt pgtype.Timestamp
+	Limit     int32
+}
+
+func (q *Queries) DeleteExpiredNotes(ctx context.Context, arg DeleteExpiredNotesParams) (int64, error) {
+	result, err := q.db.Exec(ctx, deleteExpiredNotes, arg.ExpiresAt, arg.Limit)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected(), nil
+}
+
 const deleteNote = `-- name: DeleteNote :exec
 DELETE FROM note
 WHERE id = $1
@@ -58,7 +114,7 @@ func (q *Queries) DeleteNote(ctx context.Context, id pgtype.Int8) error {
 }
 
 const getNote = `-- name: GetNote :one
-SELECT id, content, created_at, expires_at, expires_at_timezone, key_hash
+SELECT id, content, created_at, expires_at, expires_at_timezone, key_hash, views_remaining, failed_attempts
 FROM note
 WHERE id = $1
 `
@@ -73,6 +129,51 @@ func (q *Queries) GetNote(ctx context.Context, id pgtype.Int8) (Note, error) {
 		&i.ExpiresAt,
 		&i.ExpiresAtTimezone,
 		&i.KeyHash,
+		&i.ViewsRemaining,
+		&i.FailedAttempts,
+	)
+	return i, err
+}
+
+const recordFailedAttempt = `-- name: RecordFailedAttempt :one
+UPDATE note
+SET failed_attempts = failed_attempts + 1
+WHERE id = $1
+RETURNING failed_attempts
+`
+
+func (q *Queries) RecordFailedAttempt(ctx context.Context, id pgtype.Int8) (int32, error) {
+	row := q.db.QueryRow(ctx, recordFailedAttempt, id)
+	var failed_attempts int32
+	err := row.Scan(&failed_attempts)
+	return failed_attempts, err
+}
+
+const viewNote = `-- name: ViewNote :one
+UPDATE note
+SET views_remaining = views_remaining - 1
+WHERE id = $1 AND key_hash = $2 AND views_remaining = $3 AND views_remaining > 1
+RETURNING id, content, created_at, expires_at, expires_at_timezone, key_hash, views_remaining, failed_attempts
+`
+
+type ViewNoteParams struct {
+	ID             pgtype.Int8
+	KeyHash        []byte
+	ViewsRemaining int32
+}
+
+func (q *Queries) ViewNote(ctx context.Context, arg ViewNoteParams) (Note, error) {
+	row := q.db.QueryRow(ctx, viewNote, arg.ID, arg.KeyHash, arg.ViewsRemaining)
+	var i Note
+	err := row.Scan(
+		&i.ID,
+		&i.Content,
+		&i.CreatedAt,
+		&i.ExpiresAt,
+		&i.ExpiresAtTimezone,
+		&i.KeyHash,
+		&i.ViewsRemaining,
+		&i.FailedAttempts,
 	)
 	return i, err
 }
@@ -0,0 +1,43 @@
+package db
+
+import (
+	"context"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/ledorub/snote-api/internal/db/notetest"
+	"io"
+	"log/slog"
+	"os"
+	"testing"
+)
+
+// TestNoteRepository runs against the Postgres database SNOTE_TEST_POSTGRES_DSN points to. Its notes are
+// truncated before every test, so it must not be a database anything else uses.
+func TestNoteRepository(t *testing.T) {
+	dsn := os.Getenv("SNOTE_TEST_POSTGRES_DSN")
+	if dsn == "" {
+		t.Skip("SNOTE_TEST_POSTGRES_DSN is not set")
+	}
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+
+	migrator, err := NewMigrator(logger, dsn)
+	if err != nil {
+		t.Fatalf("NewMigrator() error = %v", err)
+	}
+	defer migrator.Close()
+	if err = migrator.Up(); err != nil {
+		t.Fatalf("Up() error = %v", err)
+	}
+
+	pool, err := pgxpool.New(context.Background(), dsn)
+	if err != nil {
+		t.Fatalf("pgxpool.New() error = %v", err)
+	}
+	defer pool.Close()
+
+	notetest.Run(t, func(t *testing.T) notetest.NoteRepository {
+		if _, err := pool.Exec(context.Background(), "TRUNCATE note RESTART IDENTITY"); err != nil {
+			t.Fatalf("truncating notes failed: %v", err)
+		}
+		return NewNoteRepository(logger, New(pool))
+	})
+}
@@ -0,0 +1,324 @@
+// Package notetest provides the conformance tests every note storage has to pass.
+package notetest
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"github.com/ledorub/snote-api/internal"
+	"sync"
+	"testing"
+	"time"
+)
+
+type NoteRepository interface {
+	Create(ctx context.Context, note *internal.NoteModel) (*internal.NoteModel, error)
+	Get(ctx context.Context, id uint64) (*internal.NoteModel, error)
+	View(ctx context.Context, id uint64, keyHash []byte, viewsRemaining uint) (*internal.NoteModel, error)
+	Delete(ctx context.Context, id uint64) error
+	RecordFailedAttempt(ctx context.Context, id uint64) (uint, error)
+	DeleteExpired(ctx context.Context, before time.Time, limit uint64) (uint64, error)
+}
+
+// Run runs the conformance tests against the storage newRepo returns. Every test gets a repository of its own,
+// which must not hold any notes yet.
+//
+// Storages need not check whether notes have expired, since the note service enforces expiry itself, in the time
+// zone of the note. Whether a storage hands out expired notes until DeleteExpired removes them is up to it,
+// so it is left to the tests of every storage.
+func Run(t *testing.T, newRepo func(t *testing.T) NoteRepository) {
+	tests := []struct {
+		name string
+		test func(t *testing.T, repo NoteRepository)
+	}{
+		{name: "create and get", test: testCreateAndGet},
+		{name: "get missing note", test: testGetMissing},
+		{name: "view decrements views", test: testViewDecrements},
+		{name: "view burns note", test: testViewBurns},
+		{name: "view with wrong key hash", test: testViewWrongKeyHash},
+		{name: "view with stale views", test: testViewStaleViews},
+		{name: "concurrent views", test: testConcurrentViews},
+		{name: "delete", test: testDelete},
+		{name: "failed attempts", test: testFailedAttempts},
+		{name: "delete expired", test: testDeleteExpired},
+		{name: "delete expired in batches", test: testDeleteExpiredBatches},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.test(t, newRepo(t))
+		})
+	}
+}
+
+var (
+	keyHash      = bytes.Repeat([]byte{0xff}, 32)
+	wrongKeyHash = bytes.Repeat([]byte{0xfe}, 32)
+)
+
+// newNote returns a note which expires in expiresIn. Times are truncated to microseconds, the precision
+// of the SQL storages.
+func newNote(expiresIn time.Duration, views uint) *internal.NoteModel {
+	content := "content"
+	now := time.Now().UTC().Truncate(time.Microsecond)
+	return &internal.NoteModel{
+		Content:           &content,
+		CreatedAt:         now,
+		ExpiresAt:         now.Add(expiresIn),
+		ExpiresAtTimeZone: "Europe/Berlin",
+		KeyHash:           bytes.Clone(keyHash),
+		ViewsRemaining:    views,
+	}
+}
+
+func create(t *testing.T, repo NoteRepository, note *internal.NoteModel) *internal.NoteModel {
+	t.Helper()
+	created, err := repo.Create(context.Background(), note)
+	if err != nil {
+		t.Fatalf("Create() error = %v", err)
+	}
+	return created
+}
+
+func assertNotFound(t *testing.T, repo NoteRepository, id uint64) {
+	t.Helper()
+	if _, err := repo.Get(context.Background(), id); !errors.Is(err, internal.ErrNoteNotFound) {
+		t.Errorf("Get() error = %v, want %v", err, internal.ErrNoteNotFound)
+	}
+}
+
+func assertViews(t *testing.T, repo NoteRepository, id uint64, want uint) {
+	t.Helper()
+	note, err := repo.Get(context.Background(), id)
+	if err != nil {
+		t.Fatalf("Get() error = %v", err)
+	}
+	if note.ViewsRemaining != want {
+		t.Errorf("ViewsRemaining = %d, want %d", note.ViewsRemaining, want)
+	}
+}
+
+func testCreateAndGet(t *testing.T, repo NoteRepository) {
+	want := newNote(time.Hour, 3)
+	created := create(t, repo, newNote(time.Hour, 3))
+	if created.ID == 0 {
+		t.Error("Create() assigned no ID")
+	}
+
+	got, err := repo.Get(context.Background(), created.ID)
+	if err != nil {
+		t.Fatalf("Get() error = %v", err)
+	}
+	if got.ID != created.ID {
+		t.Errorf("ID = %d, want %d", got.ID, created.ID)
+	}
+	if got.Content == nil || *got.Content != *want.Content {
+		t.Errorf("Content = %v, want %q", got.Content, *want.Content)
+	}
+	if !got.CreatedAt.Equal(created.CreatedAt) {
+		t.Errorf("CreatedAt = %v, want %v", got.CreatedAt, created.CreatedAt)
+	}
+	if !got.ExpiresAt.Equal(created.ExpiresAt) {
+		t.Errorf("ExpiresAt = %v, want %v", got.ExpiresAt, created.ExpiresAt)
+	}
+	if got.ExpiresAtTimeZone != want.ExpiresAtTimeZone {
+		t.Errorf("ExpiresAtTimeZone = %q, want %q", got.ExpiresAtTimeZone, want.ExpiresAtTimeZone)
+	}
+	if !bytes.Equal(got.KeyHash, want.KeyHash) {
+		t.Errorf("KeyHash = %x, want %x", got.KeyHash, want.KeyHash)
+	}
+	if got.ViewsRemaining != want.ViewsRemaining {
+		t.Errorf("ViewsRemaining = %d, want %d", got.ViewsRemaining, want.ViewsRemaining)
+	}
+
+	other := create(t, repo, newNote(time.Hour, 1))
+	if other.ID == created.ID {
+		t.Errorf("Create() assigned ID %d twice", other.ID)
+	}
+}
+
+func testGetMissing(t *testing.T, repo NoteRepository) {
+	assertNotFound(t, repo, 1)
+}
+
+func testViewDecrements(t *testing.T, repo NoteRepository) {
+	note := create(t, repo, newNote(time.Hour, 3))
+	for views := uint(3); views > 1; views-- {
+		viewed, err := repo.View(context.Background(), note.ID, keyHash, views)
+		if err != nil {
+			t.Fatalf("View() error = %v", err)
+		}
+		if viewed.ViewsRemaining != views-1 {
+			t.Errorf("View() ViewsRemaining = %d, want %d", viewed.ViewsRemaining, views-1)
+		}
+		if viewed.Content == nil || *viewed.Content != *note.Content {
+			t.Errorf("View() Content = %v, want %q", viewed.Content, *note.Content)
+		}
+		assertViews(t, repo, note.ID, views-1)
+	}
+}
+
+func testViewBurns(t *testing.T, repo NoteRepository) {
+	note := create(t, repo, newNote(time.Hour, 1))
+	viewed, err := repo.View(context.Background(), note.ID, keyHash, 1)
+	if err != nil {
+		t.Fatalf("View() error = %v", err)
+	}
+	if viewed.ViewsRemaining != 0 {
+		t.Errorf("View() ViewsRemaining = %d, want 0", viewed.ViewsRemaining)
+	}
+	assertNotFound(t, repo, note.ID)
+	_, err = repo.View(context.Background(), note.ID, keyHash, 1)
+	if !errors.Is(err, internal.ErrNoteNotFound) {
+		t.Errorf("View() of a burnt note error = %v, want %v", err, internal.ErrNoteNotFound)
+	}
+}
+
+func testViewWrongKeyHash(t *testing.T, repo NoteRepository) {
+	note := create(t, repo, newNote(time.Hour, 2))
+	_, err := repo.View(context.Background(), note.ID, wrongKeyHash, 2)
+	if !errors.Is(err, internal.ErrNoteNotFound) {
+		t.Errorf("View() with a wrong key hash error = %v, want %v", err, internal.ErrNoteNotFound)
+	}
+	assertViews(t, repo, note.ID, 2)
+}
+
+func testViewStaleViews(t *testing.T, repo NoteRepository) {
+	note := create(t, repo, newNote(time.Hour, 2))
+	_, err := repo.View(context.Background(), note.ID, keyHash, 3)
+	if !errors.Is(err, internal.ErrNoteNotFound) {
+		t.Errorf("View() with stale views error = %v, want %v", err, internal.ErrNoteNotFound)
+	}
+	assertViews(t, repo, note.ID, 2)
+}
+
+// testConcurrentViews has readers race for the views of a note, the way the note service does: every reader
+// looks the note up and views it with the views it has seen, starting over when another reader has been faster.
+// Every view has to be handed out exactly once.
+func testConcurrentViews(t *testing.T, repo NoteRepository) {
+	const (
+		views   = 5
+		readers = 20
+	)
+	note := create(t, repo, newNote(time.Hour, views))
+
+	var (
+		wg      sync.WaitGroup
+		mu      sync.Mutex
+		viewed  = make(map[uint]int)
+		start   = make(chan struct{})
+		readErr error
+	)
+	for i := 0; i < readers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			<-start
+			for {
+				got, err := repo.Get(context.Background(), note.ID)
+				if errors.Is(err, internal.ErrNoteNotFound) {
+					return
+				}
+				if err == nil {
+					got, err = repo.View(context.Background(), note.ID, keyHash, got.ViewsRemaining)
+				}
+				if errors.Is(err, internal.ErrNoteNotFound) {
+					continue
+				}
+				mu.Lock()
+				if err != nil {
+					readErr = err
+				} else {
+					viewed[got.ViewsRemaining]++
+				}
+				mu.Unlock()
+				if err != nil {
+					return
+				}
+			}
+		}()
+	}
+	close(start)
+	wg.Wait()
+
+	if readErr != nil {
+		t.Fatalf("concurrent read error = %v", readErr)
+	}
+	for remaining := uint(0); remaining < views; remaining++ {
+		if viewed[remaining] != 1 {
+			t.Errorf("view leaving %d views handed out %d times, want 1", remaining, viewed[remaining])
+		}
+	}
+	if len(viewed) != views {
+		t.Errorf("handed out %d distinct views, want %d", len(viewed), views)
+	}
+	assertNotFound(t, repo, note.ID)
+}
+
+func testDelete(t *testing.T, repo NoteRepository) {
+	note := create(t, repo, newNote(time.Hour, 1))
+	if err := repo.Delete(context.Background(), note.ID); err != nil {
+		t.Fatalf("Delete() error = %v", err)
+	}
+	assertNotFound(t, repo, note.ID)
+	if err := repo.Delete(context.Background(), note.ID); err != nil {
+		t.Errorf("Delete() of a missing note error = %v", err)
+	}
+}
+
+func testFailedAttempts(t *testing.T, repo NoteRepository) {
+	note := create(t, repo, newNote(time.Hour, 1))
+	for want := uint(1); want <= 3; want++ {
+		attempts, err := repo.RecordFailedAttempt(context.Background(), note.ID)
+		if err != nil {
+			t.Fatalf("RecordFailedAttempt() error = %v", err)
+		}
+		if attempts != want {
+			t.Errorf("RecordFailedAttempt() = %d, want %d", attempts, want)
+		}
+	}
+	assertViews(t, repo, note.ID, 1)
+
+	_, err := repo.RecordFailedAttempt(context.Background(), note.ID+1)
+	if !errors.Is(err, internal.ErrNoteNotFound) {
+		t.Errorf("RecordFailedAttempt() of a missing note error = %v, want %v", err, internal.ErrNoteNotFound)
+	}
+}
+
+func testDeleteExpired(t *testing.T, repo NoteRepository) {
+	expired := create(t, repo, newNote(-time.Hour, 1))
+	live := create(t, repo, newNote(time.Hour, 2))
+	exhausted := create(t, repo, newNote(time.Hour, 0))
+
+	deleted, err := repo.DeleteExpired(context.Background(), time.Now(), 10)
+	if err != nil {
+		t.Fatalf("DeleteExpired() error = %v", err)
+	}
+	if deleted != 2 {
+		t.Errorf("DeleteExpired() = %d, want 2", deleted)
+	}
+	assertNotFound(t, repo, expired.ID)
+	assertNotFound(t, repo, exhausted.ID)
+	assertViews(t, repo, live.ID, 2)
+}
+
+func testDeleteExpiredBatches(t *testing.T, repo NoteRepository) {
+	const notes = 5
+	for i := 0; i < notes; i++ {
+		create(t, repo, newNote(-time.Hour, 1))
+	}
+
+	deleted, err := repo.DeleteExpired(context.Background(), time.Now(), 3)
+	if err != nil {
+		t.Fatalf("DeleteExpired() error = %v", err)
+	}
+	if deleted != 3 {
+		t.Errorf("DeleteExpired() = %d, want 3", deleted)
+	}
+	deleted, err = repo.DeleteExpired(context.Background(), time.Now(), 3)
+	if err != nil {
+		t.Fatalf("DeleteExpired() error = %v", err)
+	}
+	if deleted != notes-3 {
+		t.Errorf("DeleteExpired() = %d, want %d", deleted, notes-3)
+	}
+}
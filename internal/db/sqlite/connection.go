@@ -0,0 +1,38 @@
+// Package sqlite provides a note storage backed by a single SQLite file for single-node deployments.
+package sqlite
+
+import (
+	"context"
+	"database/sql"
+	_ "embed"
+	"fmt"
+	_ "modernc.org/sqlite"
+	"net/url"
+)
+
+//go:embed schema.sql
+var schema string
+
+// Open opens the database file at path, creating it if needed, and makes sure the schema is in place.
+func Open(ctx context.Context, path string) (*sql.DB, error) {
+	dsn := (&url.URL{
+		Scheme: "file",
+		Opaque: path,
+		RawQuery: url.Values{
+			"_pragma": {"busy_timeout(5000)", "journal_mode(WAL)", "foreign_keys(ON)"},
+		}.Encode(),
+	}).String()
+	conn, err := sql.Open("sqlite", dsn)
+	if err != nil {
+		return nil, fmt.Errorf("SQLite open: %w", err)
+	}
+	if err = conn.PingContext(ctx); err != nil {
+		conn.Close()
+		return nil, fmt.Errorf("SQLite ping: %w", err)
+	}
+	if _, err = conn.ExecContext(ctx, schema); err != nil {
+		conn.Close()
+		return nil, fmt.Errorf("SQLite schema: %w", err)
+	}
+	return conn, nil
+}
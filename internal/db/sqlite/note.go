@@ -0,0 +1,201 @@
+package sqlite
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"github.com/ledorub/snote-api/internal"
+	"log/slog"
+	"math"
+	"time"
+)
+
+const noteColumns = `id, content, created_at, expires_at, expires_at_timezone, key_hash, views_remaining`
+
+const createNote = `
+INSERT INTO note (
+    content, created_at, expires_at, expires_at_timezone, key_hash, views_remaining
+) VALUES (
+    ?, ?, ?, ?, ?, ?
+) RETURNING ` + noteColumns
+
+const getNote = `
+SELECT ` + noteColumns + `
+FROM note
+WHERE id = ?`
+
+const viewNote = `
+UPDATE note
+SET views_remaining = views_remaining - 1
+WHERE id = ? AND key_hash = ? AND views_remaining = ? AND views_remaining > 1
+RETURNING ` + noteColumns
+
+const burnNote = `
+DELETE FROM note
+WHERE id = ? AND key_hash = ? AND views_remaining = 1
+RETURNING ` + noteColumns
+
+const deleteNote = `
+DELETE FROM note
+WHERE id = ?`
+
+const recordFailedAttempt = `
+UPDATE note
+SET failed_attempts = failed_attempts + 1
+WHERE id = ?
+RETURNING failed_attempts`
+
+const deleteExpiredNotes = `
+DELETE FROM note
+WHERE id IN (
+    SELECT expired.id
+    FROM note AS expired
+    WHERE expired.expires_at <= ? OR expired.views_remaining = 0
+    ORDER BY expired.expires_at
+    LIMIT ?
+)`
+
+type NoteRepository struct {
+	logger *slog.Logger
+	db     *sql.DB
+}
+
+func NewNoteRepository(logger *slog.Logger, db *sql.DB) *NoteRepository {
+	return &NoteRepository{logger: logger, db: db}
+}
+
+func (r *NoteRepository) Create(ctx context.Context, note *internal.NoteModel) (*internal.NoteModel, error) {
+	row := r.db.QueryRowContext(ctx, createNote,
+		*note.Content,
+		note.CreatedAt.UnixMicro(),
+		note.ExpiresAt.UnixMicro(),
+		note.ExpiresAtTimeZone,
+		note.KeyHash,
+		note.ViewsRemaining,
+	)
+	createdNote, err := scanNote(row)
+	if err != nil {
+		return &internal.NoteModel{}, fmt.Errorf("creation failed: %w", err)
+	}
+	return createdNote, nil
+}
+
+// Get returns internal.ErrNoteNotFound for IDs which are not stored, including those which could never be.
+func (r *NoteRepository) Get(ctx context.Context, id uint64) (*internal.NoteModel, error) {
+	if id > math.MaxInt64 {
+		return nil, internal.ErrNoteNotFound
+	}
+	note, err := scanNote(r.db.QueryRowContext(ctx, getNote, int64(id)))
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, internal.ErrNoteNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("retrieving failed: %w", err)
+	}
+	return note, nil
+}
+
+// View atomically decrements the remaining views of the note matching id and keyHash and returns it.
+// Nothing is taken if the note has no longer got viewsRemaining views, i.e. another reader has viewed it since
+// the caller looked it up. The last view deletes the note in the same statement, so it is never handed out
+// more times than allowed and its content is gone as soon as it has been read.
+// internal.ErrNoteNotFound is returned if no note matches.
+// keyHash is not compared in constant time. It only keeps a note from being burnt with a key hash nobody has
+// checked, so callers compare it in constant time beforehand.
+func (r *NoteRepository) View(
+	ctx context.Context,
+	id uint64,
+	keyHash []byte,
+	viewsRemaining uint,
+) (*internal.NoteModel, error) {
+	if id > math.MaxInt64 {
+		return nil, internal.ErrNoteNotFound
+	}
+	var row *sql.Row
+	if viewsRemaining == 1 {
+		row = r.db.QueryRowContext(ctx, burnNote, int64(id), keyHash)
+	} else {
+		row = r.db.QueryRowContext(ctx, viewNote, int64(id), keyHash, viewsRemaining)
+	}
+	note, err := scanNote(row)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, internal.ErrNoteNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("viewing failed: %w", err)
+	}
+	if viewsRemaining == 1 {
+		note.ViewsRemaining = 0
+	}
+	return note, nil
+}
+
+func (r *NoteRepository) Delete(ctx context.Context, id uint64) error {
+	if id > math.MaxInt64 {
+		return ErrIntOverflow
+	}
+	if _, err := r.db.ExecContext(ctx, deleteNote, int64(id)); err != nil {
+		return fmt.Errorf("deletion failed: %w", err)
+	}
+	return nil
+}
+
+// RecordFailedAttempt increments the number of wrong key hashes presented for the note and returns it.
+// internal.ErrNoteNotFound is returned if the note does not exist.
+func (r *NoteRepository) RecordFailedAttempt(ctx context.Context, id uint64) (uint, error) {
+	if id > math.MaxInt64 {
+		return 0, ErrIntOverflow
+	}
+	var attempts uint
+	err := r.db.QueryRowContext(ctx, recordFailedAttempt, int64(id)).Scan(&attempts)
+	if errors.Is(err, sql.ErrNoRows) {
+		return 0, internal.ErrNoteNotFound
+	}
+	if err != nil {
+		return 0, fmt.Errorf("failed attempt recording failed: %w", err)
+	}
+	return attempts, nil
+}
+
+func (r *NoteRepository) DeleteExpired(ctx context.Context, before time.Time, limit uint64) (uint64, error) {
+	if limit > math.MaxInt64 {
+		return 0, ErrIntOverflow
+	}
+	result, err := r.db.ExecContext(ctx, deleteExpiredNotes, before.UTC().UnixMicro(), int64(limit))
+	if err != nil {
+		return 0, fmt.Errorf("expired notes deletion failed: %w", err)
+	}
+	deleted, err := result.RowsAffected()
+	if err != nil {
+		return 0, fmt.Errorf("expired notes deletion failed: %w", err)
+	}
+	return uint64(deleted), nil
+}
+
+func scanNote(row *sql.Row) (*internal.NoteModel, error) {
+	var (
+		note      internal.NoteModel
+		id        int64
+		content   string
+		createdAt int64
+		expiresAt int64
+	)
+	err := row.Scan(
+		&id,
+		&content,
+		&createdAt,
+		&expiresAt,
+		&note.ExpiresAtTimeZone,
+		&note.KeyHash,
+		&note.ViewsRemaining,
+	)
+	if err != nil {
+		return nil, err
+	}
+	note.ID = uint64(id)
+	note.Content = &content
+	note.CreatedAt = time.UnixMicro(createdAt)
+	note.ExpiresAt = time.UnixMicro(expiresAt).UTC()
+	return &note, nil
+}
@@ -0,0 +1,21 @@
+package sqlite
+
+import (
+	"context"
+	"github.com/ledorub/snote-api/internal/db/notetest"
+	"io"
+	"log/slog"
+	"path/filepath"
+	"testing"
+)
+
+func TestNoteRepository(t *testing.T) {
+	notetest.Run(t, func(t *testing.T) notetest.NoteRepository {
+		conn, err := Open(context.Background(), filepath.Join(t.TempDir(), "snote.db"))
+		if err != nil {
+			t.Fatalf("Open() error = %v", err)
+		}
+		t.Cleanup(func() { conn.Close() })
+		return NewNoteRepository(slog.New(slog.NewTextHandler(io.Discard, nil)), conn)
+	})
+}
@@ -0,0 +1,12 @@
+CREATE TABLE IF NOT EXISTS note (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    content TEXT NOT NULL CHECK (length(content) <= 1048576),
+    created_at INTEGER NOT NULL,
+    expires_at INTEGER NOT NULL,
+    expires_at_timezone TEXT NOT NULL,
+    key_hash BLOB NOT NULL CHECK (length(key_hash) = 32),
+    views_remaining INTEGER NOT NULL DEFAULT 1 CHECK (views_remaining >= 0),
+    failed_attempts INTEGER NOT NULL DEFAULT 0
+);
+
+CREATE INDEX IF NOT EXISTS idx_note_expires_at ON note (expires_at);
@@ -0,0 +1,5 @@
+package sqlite
+
+import "errors"
+
+var ErrIntOverflow = errors.New("integer overflow")
@@ -0,0 +1,63 @@
+package db
+
+import (
+	"context"
+	"errors"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	semconv "go.opentelemetry.io/otel/semconv/v1.26.0"
+	"go.opentelemetry.io/otel/trace"
+	"strings"
+)
+
+const (
+	tracerName       = "github.com/ledorub/snote-api/internal/db"
+	sqlcNamePrefix   = "-- name: "
+	unnamedQuerySpan = "postgresql query"
+)
+
+// queryTracer starts a span for every query. Only the SQL is recorded: the arguments carry note contents
+// and key hashes.
+type queryTracer struct {
+	tracer trace.Tracer
+}
+
+func newQueryTracer(tracerProvider trace.TracerProvider) *queryTracer {
+	return &queryTracer{tracer: tracerProvider.Tracer(tracerName)}
+}
+
+func (t *queryTracer) TraceQueryStart(ctx context.Context, _ *pgx.Conn, data pgx.TraceQueryStartData) context.Context {
+	ctx, _ = t.tracer.Start(
+		ctx,
+		querySpanName(data.SQL),
+		trace.WithSpanKind(trace.SpanKindClient),
+		trace.WithAttributes(semconv.DBSystemPostgreSQL, semconv.DBQueryText(data.SQL)),
+	)
+	return ctx
+}
+
+// TraceQueryEnd ends the span. Error messages are not recorded since Postgres may quote the offending value.
+func (t *queryTracer) TraceQueryEnd(ctx context.Context, _ *pgx.Conn, data pgx.TraceQueryEndData) {
+	span := trace.SpanFromContext(ctx)
+	if data.Err != nil {
+		var pgErr *pgconn.PgError
+		if errors.As(data.Err, &pgErr) {
+			span.SetAttributes(attribute.String("db.response.status_code", pgErr.Code))
+		}
+		span.SetStatus(codes.Error, "")
+	}
+	span.End()
+}
+
+// querySpanName takes the name of a query generated by sqlc from its leading "-- name: CreateNote :one" comment.
+func querySpanName(sql string) string {
+	header, _, _ := strings.Cut(sql, "\n")
+	if name, isNamed := strings.CutPrefix(header, sqlcNamePrefix); isNamed {
+		if fields := strings.Fields(name); len(fields) > 0 {
+			return fields[0]
+		}
+	}
+	return unnamedQuerySpan
+}
@@ -0,0 +1,195 @@
+package encdec
+
+import (
+	"errors"
+	"fmt"
+	"math/bits"
+	"strings"
+	"unicode/utf8"
+)
+
+const (
+	B58Alphabet             = "123456789ABCDEFGHJKLMNPQRSTUVWXYZabcdefghijkmnopqrstuvwxyz"
+	DefaultIDMinLength      = 10
+	DefaultIDSeparator      = "-"
+	DefaultIDGroupSize      = 4
+	idFormatMinAlphabetSize = 2
+)
+
+var ErrIDOverflow = errors.New("id is too large")
+
+// IDFormat encodes integer IDs as strings of alphabet chars, left-padded with the first alphabet char
+// up to minLength and split into groups of groupSize chars by separator.
+type IDFormat struct {
+	alphabet  []rune
+	index     map[rune]uint64
+	minLength int
+	separator string
+	groupSize int
+}
+
+// NewIDFormat creates an ID format. Grouping is disabled if either separator is empty or groupSize is 0.
+func NewIDFormat(alphabet string, minLength int, separator string, groupSize int) (*IDFormat, error) {
+	runes := []rune(alphabet)
+	if len(runes) < idFormatMinAlphabetSize {
+		return nil, fmt.Errorf("id format: alphabet should consist of at least %d chars", idFormatMinAlphabetSize)
+	}
+	index := make(map[rune]uint64, len(runes))
+	for i, char := range runes {
+		if _, exists := index[char]; exists {
+			return nil, fmt.Errorf("id format: alphabet contains %q more than once", char)
+		}
+		if separator != "" && strings.ContainsRune(separator, char) {
+			return nil, fmt.Errorf("id format: separator contains alphabet char %q", char)
+		}
+		index[char] = uint64(i)
+	}
+	if minLength < 0 {
+		return nil, errors.New("id format: minimum length should not be negative")
+	}
+	if groupSize < 0 {
+		return nil, errors.New("id format: group size should not be negative")
+	}
+	if separator == "" {
+		groupSize = 0
+	}
+
+	return &IDFormat{
+		alphabet:  runes,
+		index:     index,
+		minLength: minLength,
+		separator: separator,
+		groupSize: groupSize,
+	}, nil
+}
+
+// DefaultIDFormat returns the format IDs have been issued in from the beginning: 10 base58 chars split by dashes
+// into groups of 4, e.g. 1111-1111-2j.
+func DefaultIDFormat() *IDFormat {
+	format, _ := NewIDFormat(B58Alphabet, DefaultIDMinLength, DefaultIDSeparator, DefaultIDGroupSize)
+	return format
+}
+
+// Encode never fails. It returns an error only to be interchangeable with encoders which may.
+func (f *IDFormat) Encode(id uint64) (string, error) {
+	base := uint64(len(f.alphabet))
+	var digits []rune
+	for id > 0 || len(digits) == 0 {
+		digits = append(digits, f.alphabet[id%base])
+		id /= base
+	}
+	for i, j := 0, len(digits)-1; i < j; i, j = i+1, j-1 {
+		digits[i], digits[j] = digits[j], digits[i]
+	}
+
+	enc := padStringWith(string(digits), string(f.alphabet[0]), f.minLength)
+	if f.groupSize > 0 {
+		enc = insertStringSeparator(enc, f.separator, f.groupSize)
+	}
+	return enc, nil
+}
+
+func (f *IDFormat) Decode(str string) (uint64, error) {
+	if str == "" {
+		return 0, errors.New("id decoding error: id is empty")
+	}
+	if f.groupSize > 0 {
+		var err error
+		if str, err = removeStringSeparator(str, f.separator, f.groupSize); err != nil {
+			return 0, fmt.Errorf("id decoding error: %w", err)
+		}
+	}
+
+	base := uint64(len(f.alphabet))
+	var num uint64
+	for _, char := range str {
+		digit, exists := f.index[char]
+		if !exists {
+			return 0, fmt.Errorf("id decoding error: unexpected char %q", char)
+		}
+		hi, lo := bits.Mul64(num, base)
+		lo, carry := bits.Add64(lo, digit, 0)
+		if hi != 0 || carry != 0 {
+			return 0, ErrIDOverflow
+		}
+		num = lo
+	}
+	return num, nil
+}
+
+// Validate checks that str is an ID in this format.
+func (f *IDFormat) Validate(str string) bool {
+	if _, err := f.Decode(str); err != nil {
+		return false
+	}
+	charCount := utf8.RuneCountInString(str)
+	if f.groupSize > 0 {
+		charCount -= strings.Count(str, f.separator) * utf8.RuneCountInString(f.separator)
+	}
+	return charCount >= f.minLength
+}
+
+func padStringWith(s, padding string, totalWidth int) string {
+	stringWidth := utf8.RuneCountInString(s)
+	paddingWidth := utf8.RuneCountInString(padding)
+	fillWidth := totalWidth - stringWidth
+	if fillWidth > 0 {
+		fill := strings.Repeat(padding, fillWidth/paddingWidth) + padding[:fillWidth%paddingWidth]
+		s = fill + s
+	}
+	return s
+}
+
+func insertStringSeparator(s, sep string, segmentWidth int) string {
+	approxSepCount := len(s) / segmentWidth
+	if len(s)%segmentWidth == 0 {
+		approxSepCount--
+	}
+	newWidth := len(s) + approxSepCount*len(sep)
+	strBytes := make([]byte, newWidth)
+
+	writeI := 0
+	for byteI, charI := 0, 0; byteI < len(s)-1; byteI++ {
+		strBytes[writeI] = s[byteI]
+		writeI++
+
+		if utf8.RuneStart(s[byteI+1]) {
+			charI++
+		}
+		if charI == segmentWidth {
+			for i := 0; i < len(sep); i++ {
+				strBytes[writeI] = sep[i]
+				writeI++
+			}
+			charI = 0
+		}
+	}
+	strBytes[writeI] = s[len(s)-1]
+	return string(strBytes[:writeI+1])
+}
+
+func removeStringSeparator(s, sep string, segmentWidth int) (string, error) {
+	strBytes := make([]byte, len(s))
+
+	writeI := 0
+	for byteI, charI := 0, 0; byteI < len(s); byteI++ {
+		if byteI == len(s)-1 || utf8.RuneStart(s[byteI+1]) {
+			charI++
+		}
+
+		strBytes[writeI] = s[byteI]
+		writeI++
+
+		if charI == segmentWidth && byteI != len(s)-1 {
+			charI = 0
+			substr := s[byteI+1 : min(byteI+1+len(sep), len(s))]
+			if substr != sep {
+				return "", fmt.Errorf(
+					"removeStringSeparator: expected %s at s[%d], but got %s", sep, byteI+1, substr,
+				)
+			}
+			byteI += len(sep)
+		}
+	}
+	return string(strBytes[:writeI]), nil
+}
@@ -0,0 +1,37 @@
+package encdec
+
+import (
+	"errors"
+	"fmt"
+	"github.com/pelletier/go-toml/v2"
+	"io"
+)
+
+type TOMLDecoder struct{}
+
+func (d *TOMLDecoder) Decode(data io.Reader, dst any) error {
+	dec := toml.NewDecoder(data)
+	dec.DisallowUnknownFields()
+
+	if err := dec.Decode(dst); err != nil {
+		var (
+			strictMissingError *toml.StrictMissingError
+			decodeError        *toml.DecodeError
+		)
+
+		switch {
+		case errors.As(err, &strictMissingError):
+			return fmt.Errorf("toml contains unknown keys:\n%s", strictMissingError.String())
+		case errors.As(err, &decodeError):
+			row, column := decodeError.Position()
+			return fmt.Errorf("data contains badly-formed TOML (at line %d, column %d): %v", row, column, decodeError)
+		default:
+			return err
+		}
+	}
+	return nil
+}
+
+func NewTOMLDecoder() *TOMLDecoder {
+	return &TOMLDecoder{}
+}
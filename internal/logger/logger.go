@@ -1,10 +1,67 @@
 package logger
 
 import (
-	"log"
+	"context"
+	"fmt"
+	"io"
+	"log/slog"
 	"os"
 )
 
-func New() *log.Logger {
-	return log.New(os.Stdout, "", log.Ldate|log.Ltime)
+const (
+	FormatText = "text"
+	FormatJSON = "json"
+)
+
+// New creates a logger writing to stdout in the format. The level may be changed while the logger is in use.
+func New(level *slog.LevelVar, format string) (*slog.Logger, error) {
+	handler, err := newHandler(os.Stdout, level, format)
+	if err != nil {
+		return nil, err
+	}
+	return slog.New(&contextHandler{Handler: handler}), nil
+}
+
+func newHandler(w io.Writer, level *slog.LevelVar, format string) (slog.Handler, error) {
+	opts := &slog.HandlerOptions{Level: level}
+	switch format {
+	case FormatText, "":
+		return slog.NewTextHandler(w, opts), nil
+	case FormatJSON:
+		return slog.NewJSONHandler(w, opts), nil
+	default:
+		return nil, fmt.Errorf("logger: unsupported format %q", format)
+	}
+}
+
+type attrsKey struct{}
+
+// WithAttrs returns a context carrying attrs, which are added to every record logged with the context,
+// e.g. by Logger.InfoContext. Never put note contents or key hashes there.
+func WithAttrs(ctx context.Context, attrs ...slog.Attr) context.Context {
+	existing, _ := ctx.Value(attrsKey{}).([]slog.Attr)
+	merged := make([]slog.Attr, 0, len(existing)+len(attrs))
+	merged = append(merged, existing...)
+	merged = append(merged, attrs...)
+	return context.WithValue(ctx, attrsKey{}, merged)
+}
+
+// contextHandler adds the attributes stored by WithAttrs to records.
+type contextHandler struct {
+	slog.Handler
+}
+
+func (h *contextHandler) Handle(ctx context.Context, record slog.Record) error {
+	if attrs, ok := ctx.Value(attrsKey{}).([]slog.Attr); ok {
+		record.AddAttrs(attrs...)
+	}
+	return h.Handler.Handle(ctx, record)
+}
+
+func (h *contextHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	return &contextHandler{Handler: h.Handler.WithAttrs(attrs)}
+}
+
+func (h *contextHandler) WithGroup(name string) slog.Handler {
+	return &contextHandler{Handler: h.Handler.WithGroup(name)}
 }
@@ -0,0 +1,31 @@
+package metrics
+
+import (
+	"strconv"
+	"time"
+)
+
+// HTTPMetrics counts served requests per route and status.
+type HTTPMetrics struct {
+	requests *CounterVec
+	duration *HistogramVec
+}
+
+func NewHTTPMetrics(r *Registry) *HTTPMetrics {
+	return &HTTPMetrics{
+		requests: r.NewCounterVec("snote_http_requests_total", "Number of served HTTP requests.", "route", "status"),
+		duration: r.NewHistogramVec(
+			"snote_http_request_duration_seconds",
+			"Time taken to serve HTTP requests.",
+			[]float64{0.005, 0.01, 0.025, 0.05, 0.1, 0.25, 0.5, 1, 2.5, 5, 10},
+			"route", "status",
+		),
+	}
+}
+
+// RequestServed records a request. route should be a route pattern rather than a path, which contains note IDs.
+func (m *HTTPMetrics) RequestServed(route string, status int, duration time.Duration) {
+	statusLabel := strconv.Itoa(status)
+	m.requests.Inc(route, statusLabel)
+	m.duration.Observe(duration.Seconds(), route, statusLabel)
+}
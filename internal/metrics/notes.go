@@ -0,0 +1,39 @@
+package metrics
+
+// NoteMetrics counts note events. No per-note identifiers are recorded.
+type NoteMetrics struct {
+	created     *CounterVec
+	read        *CounterVec
+	expired     *CounterVec
+	lockedOut   *CounterVec
+	contentSize *HistogramVec
+}
+
+func NewNoteMetrics(r *Registry) *NoteMetrics {
+	return &NoteMetrics{
+		created:   r.NewCounterVec("snote_notes_created_total", "Number of created notes."),
+		read:      r.NewCounterVec("snote_notes_read_total", "Number of times note contents were revealed."),
+		expired:   r.NewCounterVec("snote_notes_expired_total", "Number of expired notes deleted by the reaper or when they were looked up."),
+		lockedOut: r.NewCounterVec("snote_notes_locked_out_total", "Number of notes burnt after too many failed attempts."),
+		contentSize: r.NewHistogramVec(
+			"snote_note_content_size_bytes", "Size of the contents of created notes.", ExponentialBuckets(64, 4, 8),
+		),
+	}
+}
+
+func (m *NoteMetrics) NoteCreated(contentSize int) {
+	m.created.Inc()
+	m.contentSize.Observe(float64(contentSize))
+}
+
+func (m *NoteMetrics) NoteRead() {
+	m.read.Inc()
+}
+
+func (m *NoteMetrics) NotesExpired(count uint64) {
+	m.expired.Add(float64(count))
+}
+
+func (m *NoteMetrics) NoteLockedOut() {
+	m.lockedOut.Inc()
+}
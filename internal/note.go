@@ -8,6 +8,9 @@ import (
 	"time"
 )
 
+// ErrNoteNotFound is returned by storages for notes they do not have.
+var ErrNoteNotFound = errors.New("note not found")
+
 type Note struct {
 	ID                string
 	Content           *string
@@ -16,45 +19,98 @@ type Note struct {
 	ExpiresAt         time.Time
 	ExpiresAtTimeZone *time.Location
 	KeyHash           string
+	MaxViews          uint
+	ViewsRemaining    uint
+}
+
+const MinViews uint = 1
+
+const (
+	DefaultMinExpiresIn      = 10 * time.Minute
+	DefaultMaxExpiresIn      = 365 * 24 * time.Hour
+	DefaultMaxViews     uint = 10
+	// expiresAtLeeway is how much earlier than MinExpiresIn expiration dates may be, since the clock of the
+	// client runs differently and the request takes time.
+	expiresAtLeeway = time.Minute
+)
+
+// NoteLimits bound the notes which can be created.
+type NoteLimits struct {
+	MinExpiresIn time.Duration
+	MaxExpiresIn time.Duration
+	MaxViews     uint
 }
 
-func (n *Note) CheckErrors() error {
+func DefaultNoteLimits() NoteLimits {
+	return NoteLimits{
+		MinExpiresIn: DefaultMinExpiresIn,
+		MaxExpiresIn: DefaultMaxExpiresIn,
+		MaxViews:     DefaultMaxViews,
+	}
+}
+
+// Codes of validation errors. They are part of the API, so they must never change.
+const (
+	CodeContentRequired     = "content_required"
+	CodeContentTooLarge     = "content_too_large"
+	CodeCreatedAtOutOfRange = "created_at_out_of_range"
+	CodeKeyHashRequired     = "key_hash_required"
+	CodeKeyHashInvalid      = "key_hash_invalid"
+	CodeMaxViewsOutOfRange  = "max_views_out_of_range"
+	CodeExpirationConflict  = "expiration_conflict"
+	CodeExpiresInOutOfRange = "expires_in_out_of_range"
+	CodeExpiresAtOutOfRange = "expires_at_out_of_range"
+	CodeTimeZoneInvalid     = "time_zone_invalid"
+	CodeNoteIDRequired      = "note_id_required"
+	CodeNoteIDInvalid       = "note_id_invalid"
+	CodeRevealTokenRequired = "reveal_token_required"
+)
+
+func (n *Note) CheckErrors(limits NoteLimits) error {
 	v := validator.Validator{}
 
-	v.Check(validator.ValidateHyphenatedB58String(n.ID), "id should consist of latin letters and/or digits and hyphens")
-	v.Check(len(*n.Content) != 0, "content should be provided")
-	v.Check(len(*n.Content) <= 1_048_576, "content should not exceed 1 MB")
-	v.Check(
+	v.CheckField(len(*n.Content) != 0, "/content", CodeContentRequired, "content should be provided")
+	v.CheckField(len(*n.Content) <= 1_048_576, "/content", CodeContentTooLarge, "content should not exceed 1 MB")
+	v.CheckField(
 		validator.ValidateTimeInRange(n.CreatedAt, time.Now().Add(-1*time.Minute), time.Now()),
+		"", CodeCreatedAtOutOfRange,
 		"time of the creation should be in range (now - 1 min, now]",
 	)
-	v.Check(len(n.KeyHash) == 44, "key hash must be exactly 44 bytes long")
+	v.CheckField(len(n.KeyHash) == 44, "/keyHash", CodeKeyHashInvalid, "key hash must be exactly 44 bytes long")
+	v.CheckField(
+		validator.ValidateValueInRange(n.MaxViews, MinViews, limits.MaxViews),
+		"/maxViews", CodeMaxViewsOutOfRange,
+		fmt.Sprintf("max views should be in range [%d, %d]", MinViews, limits.MaxViews),
+	)
 
 	isExpiresInSet := n.ExpiresIn != 0
 	isExpiresAtSet := !n.ExpiresAt.IsZero() && n.ExpiresAtTimeZone != nil
 	hasConflict := isExpiresInSet && isExpiresAtSet || !(isExpiresInSet || isExpiresAtSet)
-	v.Check(!hasConflict, "either expiration date and time zone or expiration timeout should be provided")
+	v.CheckField(
+		!hasConflict, "", CodeExpirationConflict,
+		"either expiration date and time zone or expiration timeout should be provided",
+	)
 	if n.ExpiresIn != 0 {
-		year := 24 * 60 * 365 * time.Minute
-		v.Check(
-			n.ExpiresIn >= 10*time.Minute && n.ExpiresIn <= 1*year,
-			"expiration timeout should be in range [10 min, 365 days]",
+		v.CheckField(
+			n.ExpiresIn >= limits.MinExpiresIn && n.ExpiresIn <= limits.MaxExpiresIn,
+			"/expiresIn", CodeExpiresInOutOfRange,
+			fmt.Sprintf(
+				"expiration timeout should be in range [%s, %s]",
+				formatDuration(limits.MinExpiresIn), formatDuration(limits.MaxExpiresIn),
+			),
 		)
 	} else {
 		localCreatedAt := n.CreatedAt.In(n.ExpiresAtTimeZone)
-		expiresAtLowerBound := time.Date(
-			localCreatedAt.Year(), localCreatedAt.Month(), localCreatedAt.Day(),
-			localCreatedAt.Hour(), localCreatedAt.Minute()+9, localCreatedAt.Second(), localCreatedAt.Nanosecond(),
-			localCreatedAt.Location(),
-		)
-		expiresAtUpperBound := time.Date(
-			localCreatedAt.Year()+1, localCreatedAt.Month(), localCreatedAt.Day(),
-			localCreatedAt.Hour(), localCreatedAt.Minute(), localCreatedAt.Second(), localCreatedAt.Nanosecond(),
-			localCreatedAt.Location(),
-		)
-		v.Check(
-			validator.ValidateTimeInRange(n.ExpiresAt, expiresAtLowerBound, expiresAtUpperBound),
-			"expiration date should be in range (local time + 9 min, local time + 1 year]",
+		minExpiresIn := limits.MinExpiresIn - expiresAtLeeway
+		v.CheckField(
+			validator.ValidateTimeInRange(
+				n.ExpiresAt, localCreatedAt.Add(minExpiresIn), localCreatedAt.Add(limits.MaxExpiresIn),
+			),
+			"/expiresAt", CodeExpiresAtOutOfRange,
+			fmt.Sprintf(
+				"expiration date should be in range (local time + %s, local time + %s]",
+				formatDuration(minExpiresIn), formatDuration(limits.MaxExpiresIn),
+			),
 		)
 	}
 
@@ -71,12 +127,20 @@ func NewNote(
 	expiresAt time.Time,
 	expiresAtTimeZone string,
 	keyHash string,
+	maxViews uint,
 ) (*Note, error) {
 	tz, err := time.LoadLocation(expiresAtTimeZone)
 	if err != nil && expiresIn == 0 {
-		return &Note{}, fmt.Errorf("unable to load time zone %v: %w", tz, err)
+		return &Note{}, validator.ValidationError{
+			Code:    CodeTimeZoneInvalid,
+			Pointer: "/expiresAtTimezone",
+			Message: fmt.Sprintf("unknown time zone %q", expiresAtTimeZone),
+		}
 	}
 	expiresAt = datetime.TimeAsLocalTime(expiresAt, tz)
+	if maxViews == 0 {
+		maxViews = MinViews
+	}
 
 	note := &Note{
 		Content:           content,
@@ -85,6 +149,7 @@ func NewNote(
 		ExpiresAt:         expiresAt,
 		ExpiresAtTimeZone: tz,
 		KeyHash:           keyHash,
+		MaxViews:          maxViews,
 	}
 	return note, nil
 }
@@ -96,4 +161,27 @@ type NoteModel struct {
 	ExpiresAt         time.Time
 	ExpiresAtTimeZone string
 	KeyHash           []byte
+	ViewsRemaining    uint
+}
+
+type RevealToken struct {
+	Value     string
+	ExpiresAt time.Time
+}
+
+// formatDuration formats d in the largest of days, hours and minutes it is a whole number of.
+func formatDuration(d time.Duration) string {
+	day := 24 * time.Hour
+	switch {
+	case d == day:
+		return "1 day"
+	case d != 0 && d%day == 0:
+		return fmt.Sprintf("%d days", d/day)
+	case d != 0 && d%time.Hour == 0:
+		return fmt.Sprintf("%d h", d/time.Hour)
+	case d%time.Minute == 0:
+		return fmt.Sprintf("%d min", d/time.Minute)
+	default:
+		return d.String()
+	}
 }
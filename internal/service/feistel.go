@@ -0,0 +1,120 @@
+package service
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/binary"
+	"errors"
+	"fmt"
+)
+
+const (
+	feistelRounds   = 8
+	feistelHalfBits = 23
+	feistelHalfMask = 1<<feistelHalfBits - 1
+	// feistelDomain does not depend on the ID format, so that changing the format changes how IDs are encoded
+	// but never which IDs they are. It keeps permuted IDs as short as 8 base58 chars.
+	feistelDomain = 1 << (2 * feistelHalfBits)
+)
+
+var (
+	ErrEmptyIDSecret = errors.New("id secret must not be empty")
+	ErrIDOutOfRange  = errors.New("id is out of the range of permuted ids")
+)
+
+// FeistelIDEncDec hides the sequence behind note IDs by permuting them with a keyed Feistel network
+// before encoding them with the ID format.
+//
+// IDs up to legacyMaxID are the ones issued without the permutation before the switch. They are decoded as is,
+// and no permuted ID ever falls into that range, so both kinds of IDs are accepted during the migration window.
+// Only IDs above legacyMaxID and below the domain can be encoded.
+type FeistelIDEncDec struct {
+	format      idEncDec
+	secret      []byte
+	legacyMaxID uint64
+}
+
+// NewFeistelIDEncDec creates the encoder. legacyMaxID must be the last ID issued before the switch: permuted IDs
+// would collide with legacy IDs above it, and legacy IDs up to it would be decoded as permuted ones.
+// It is 0 if there are no legacy IDs.
+func NewFeistelIDEncDec(format idEncDec, secret []byte, legacyMaxID uint64) (*FeistelIDEncDec, error) {
+	if len(secret) == 0 {
+		return nil, ErrEmptyIDSecret
+	}
+	if legacyMaxID >= feistelDomain-1 {
+		return nil, fmt.Errorf("legacy max id %d leaves no ids below %d to permute", legacyMaxID, feistelDomain)
+	}
+	return &FeistelIDEncDec{format: format, secret: secret, legacyMaxID: legacyMaxID}, nil
+}
+
+func (ed *FeistelIDEncDec) Encode(id uint64) (string, error) {
+	if !ed.isPermutable(id) {
+		return "", ErrIDOutOfRange
+	}
+	return ed.format.Encode(ed.permute(id))
+}
+
+func (ed *FeistelIDEncDec) Validate(str string) bool {
+	return ed.format.Validate(str)
+}
+
+func (ed *FeistelIDEncDec) Decode(str string) (uint64, error) {
+	num, err := ed.format.Decode(str)
+	if err != nil {
+		return 0, err
+	}
+	if num <= ed.legacyMaxID {
+		return num, nil
+	}
+	if num >= feistelDomain {
+		return 0, ErrIDOutOfRange
+	}
+	return ed.unpermute(num), nil
+}
+
+func (ed *FeistelIDEncDec) isPermutable(id uint64) bool {
+	return id > ed.legacyMaxID && id < feistelDomain
+}
+
+// permute maps IDs above legacyMaxID onto the same range. It cycle-walks over the Feistel network
+// until the result leaves the legacy range.
+func (ed *FeistelIDEncDec) permute(id uint64) uint64 {
+	for {
+		id = ed.feistel(id, false)
+		if id > ed.legacyMaxID {
+			return id
+		}
+	}
+}
+
+func (ed *FeistelIDEncDec) unpermute(num uint64) uint64 {
+	for {
+		num = ed.feistel(num, true)
+		if num > ed.legacyMaxID {
+			return num
+		}
+	}
+}
+
+func (ed *FeistelIDEncDec) feistel(num uint64, inverse bool) uint64 {
+	left, right := num>>feistelHalfBits, num&feistelHalfMask
+	for i := 0; i < feistelRounds; i++ {
+		round := i
+		if inverse {
+			round = feistelRounds - 1 - i
+			left, right = right^ed.roundFunc(round, left), left
+		} else {
+			left, right = right, left^ed.roundFunc(round, right)
+		}
+	}
+	return left<<feistelHalfBits | right
+}
+
+func (ed *FeistelIDEncDec) roundFunc(round int, half uint64) uint64 {
+	mac := hmac.New(sha256.New, ed.secret)
+	bin := make([]byte, 9)
+	bin[0] = byte(round)
+	binary.BigEndian.PutUint64(bin[1:], half)
+	mac.Write(bin)
+	return binary.BigEndian.Uint64(mac.Sum(nil)[:8]) & feistelHalfMask
+}
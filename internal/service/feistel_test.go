@@ -0,0 +1,95 @@
+package service
+
+import (
+	"errors"
+	"github.com/ledorub/snote-api/internal/encdec"
+	"testing"
+)
+
+func TestNewFeistelIDEncDecRejectsLegacyMaxIDOutsideDomain(t *testing.T) {
+	const domain = feistelDomain
+	tests := []struct {
+		name        string
+		legacyMaxID uint64
+		wantErr     bool
+	}{
+		{name: "no legacy ids", legacyMaxID: 0, wantErr: false},
+		{name: "room for one id", legacyMaxID: domain - 2, wantErr: false},
+		{name: "no room left", legacyMaxID: domain - 1, wantErr: true},
+		{name: "beyond domain", legacyMaxID: domain, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := NewFeistelIDEncDec(encdec.DefaultIDFormat(), []byte("secret"), tt.legacyMaxID)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("NewFeistelIDEncDec() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestFeistelIDEncDec(t *testing.T) {
+	const legacyMaxID = 1000
+	ed, err := NewFeistelIDEncDec(encdec.DefaultIDFormat(), []byte("secret"), legacyMaxID)
+	if err != nil {
+		t.Fatalf("NewFeistelIDEncDec() error = %v", err)
+	}
+
+	t.Run("round trip", func(t *testing.T) {
+		for id := uint64(legacyMaxID + 1); id <= legacyMaxID+1000; id++ {
+			encoded, err := ed.Encode(id)
+			if err != nil {
+				t.Fatalf("Encode(%d) error = %v", id, err)
+			}
+			decoded, err := ed.Decode(encoded)
+			if err != nil {
+				t.Fatalf("Decode(%q) error = %v", encoded, err)
+			}
+			if decoded != id {
+				t.Fatalf("Decode(Encode(%d)) = %d", id, decoded)
+			}
+		}
+	})
+
+	t.Run("legacy ids", func(t *testing.T) {
+		legacy, _ := encdec.DefaultIDFormat().Encode(legacyMaxID)
+		decoded, err := ed.Decode(legacy)
+		if err != nil || decoded != legacyMaxID {
+			t.Errorf("Decode(%q) = %d, %v, want %d", legacy, decoded, err, legacyMaxID)
+		}
+		if _, err := ed.Encode(legacyMaxID); !errors.Is(err, ErrIDOutOfRange) {
+			t.Errorf("Encode(%d) error = %v, want %v", legacyMaxID, err, ErrIDOutOfRange)
+		}
+	})
+
+	t.Run("ids beyond domain", func(t *testing.T) {
+		if _, err := ed.Encode(feistelDomain); !errors.Is(err, ErrIDOutOfRange) {
+			t.Errorf("Encode(%d) error = %v, want %v", feistelDomain, err, ErrIDOutOfRange)
+		}
+		beyond, _ := encdec.DefaultIDFormat().Encode(feistelDomain)
+		if _, err := ed.Decode(beyond); !errors.Is(err, ErrIDOutOfRange) {
+			t.Errorf("Decode(%q) error = %v, want %v", beyond, err, ErrIDOutOfRange)
+		}
+	})
+}
+
+func TestFeistelIDEncDecDomainIsIndependentOfFormat(t *testing.T) {
+	short, err := encdec.NewIDFormat(encdec.B58Alphabet, 4, "", 0)
+	if err != nil {
+		t.Fatalf("NewIDFormat() error = %v", err)
+	}
+	defaultED, err := NewFeistelIDEncDec(encdec.DefaultIDFormat(), []byte("secret"), 0)
+	if err != nil {
+		t.Fatalf("NewFeistelIDEncDec() error = %v", err)
+	}
+	shortED, err := NewFeistelIDEncDec(short, []byte("secret"), 0)
+	if err != nil {
+		t.Fatalf("NewFeistelIDEncDec() error = %v", err)
+	}
+
+	for id := uint64(1); id <= 100; id++ {
+		if defaultED.permute(id) != shortED.permute(id) {
+			t.Fatalf("permute(%d) differs between ID formats", id)
+		}
+	}
+}
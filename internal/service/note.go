@@ -3,43 +3,152 @@ package service
 import (
 	"context"
 	"crypto/subtle"
-	"encoding/binary"
 	"errors"
 	"fmt"
 	"github.com/ledorub/snote-api/internal"
+	"github.com/ledorub/snote-api/internal/datetime"
 	"github.com/ledorub/snote-api/internal/validator"
 	"github.com/mr-tron/base58"
-	"log"
-	"strings"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+	"go.opentelemetry.io/otel/trace/noop"
+	"log/slog"
+	"sync/atomic"
 	"time"
-	"unicode/utf8"
+)
+
+const (
+	defaultMaxFailedAttempts = 10
+	tracerName               = "github.com/ledorub/snote-api/internal/service"
+	// unassignedNoteID is never handed out by the storages, whose IDs start at 1.
+	unassignedNoteID = 0
 )
 
 var ErrDoesNotExist = errors.New("does not exist")
 
+// LockoutHandler is called when a note is burnt after too many wrong key hashes.
+type LockoutHandler func(ctx context.Context, failedAttempts uint)
+
+// Metrics receives note events. It must be safe for concurrent use.
+type Metrics interface {
+	NoteCreated(contentSize int)
+	NoteRead()
+	NotesExpired(count uint64)
+	NoteLockedOut()
+}
+
+type noopMetrics struct{}
+
+func (noopMetrics) NoteCreated(int)     {}
+func (noopMetrics) NoteRead()           {}
+func (noopMetrics) NotesExpired(uint64) {}
+func (noopMetrics) NoteLockedOut()      {}
+
 type noteRepository interface {
 	Create(ctx context.Context, note *internal.NoteModel) (*internal.NoteModel, error)
 	Get(ctx context.Context, id uint64) (*internal.NoteModel, error)
+	View(ctx context.Context, id uint64, keyHash []byte, viewsRemaining uint) (*internal.NoteModel, error)
 	Delete(ctx context.Context, id uint64) error
+	RecordFailedAttempt(ctx context.Context, id uint64) (uint, error)
 }
 
 type idEncDec interface {
-	Encode(intID uint64) string
+	Encode(intID uint64) (string, error)
 	Decode(strID string) (uint64, error)
+	Validate(strID string) bool
+}
+
+type revealTokenIssuer interface {
+	Issue(noteID string, viewsRemaining uint, now time.Time) *internal.RevealToken
+	Verify(token string, noteID string, viewsRemaining uint, now time.Time) error
 }
 
 type NoteService struct {
-	logger   *log.Logger
-	repo     noteRepository
-	idEncDec idEncDec
+	logger            *slog.Logger
+	repo              noteRepository
+	idEncDec          idEncDec
+	revealTokens      revealTokenIssuer
+	maxFailedAttempts atomic.Uint64
+	noteLimits        atomic.Pointer[internal.NoteLimits]
+	onLockout         LockoutHandler
+	metrics           Metrics
+	tracer            trace.Tracer
 }
 
-func New(logger *log.Logger, repo noteRepository, idEncoderDecoder idEncDec) *NoteService {
-	return &NoteService{logger: logger, repo: repo, idEncDec: idEncoderDecoder}
+func New(
+	logger *slog.Logger,
+	repo noteRepository,
+	idEncoderDecoder idEncDec,
+	revealTokens revealTokenIssuer,
+	opts ...Opt,
+) *NoteService {
+	s := &NoteService{
+		logger:       logger,
+		repo:         repo,
+		idEncDec:     idEncoderDecoder,
+		revealTokens: revealTokens,
+		metrics:      noopMetrics{},
+		tracer:       noop.NewTracerProvider().Tracer(tracerName),
+	}
+	s.maxFailedAttempts.Store(defaultMaxFailedAttempts)
+	s.SetNoteLimits(internal.DefaultNoteLimits())
+	for _, opt := range opts {
+		opt(s)
+	}
+	return s
 }
 
-func (s *NoteService) CreateNote(ctx context.Context, note *internal.Note) (*internal.Note, error) {
-	if err := note.CheckErrors(); err != nil {
+type Opt func(s *NoteService)
+
+// WithLockout sets the number of wrong key hashes after which a note is burnt.
+// onLockout, if not nil, is called every time a note gets burnt that way.
+func WithLockout(maxFailedAttempts uint, onLockout LockoutHandler) Opt {
+	return func(s *NoteService) {
+		s.SetMaxFailedAttempts(maxFailedAttempts)
+		s.onLockout = onLockout
+	}
+}
+
+// WithNoteLimits sets the bounds new notes are checked against.
+func WithNoteLimits(limits internal.NoteLimits) Opt {
+	return func(s *NoteService) {
+		s.SetNoteLimits(limits)
+	}
+}
+
+func WithMetrics(metrics Metrics) Opt {
+	return func(s *NoteService) {
+		s.metrics = metrics
+	}
+}
+
+func WithTracerProvider(tracerProvider trace.TracerProvider) Opt {
+	return func(s *NoteService) {
+		s.tracer = tracerProvider.Tracer(tracerName)
+	}
+}
+
+// SetMaxFailedAttempts changes the lockout threshold of a running service. Zero values are ignored.
+func (s *NoteService) SetMaxFailedAttempts(maxFailedAttempts uint) {
+	if maxFailedAttempts > 0 {
+		s.maxFailedAttempts.Store(uint64(maxFailedAttempts))
+	}
+}
+
+// SetNoteLimits changes the bounds new notes are checked against. Existing notes are left as they are.
+func (s *NoteService) SetNoteLimits(limits internal.NoteLimits) {
+	s.noteLimits.Store(&limits)
+}
+
+func (s *NoteService) CreateNote(ctx context.Context, note *internal.Note) (_ *internal.Note, err error) {
+	ctx, span := s.tracer.Start(ctx, "NoteService.CreateNote")
+	defer func() { endSpan(span, err) }()
+
+	_, checkSpan := s.tracer.Start(ctx, "Note.CheckErrors")
+	err = note.CheckErrors(*s.noteLimits.Load())
+	checkSpan.End()
+	if err != nil {
 		return &internal.Note{}, fmt.Errorf("note creation failed: %w", err)
 	}
 
@@ -56,203 +165,295 @@ func (s *NoteService) CreateNote(ctx context.Context, note *internal.Note) (*int
 		ExpiresAt:         expiresAt,
 		ExpiresAtTimeZone: tz.String(),
 		KeyHash:           decodedKeyHash,
+		ViewsRemaining:    note.MaxViews,
 	}
 	createdNote, err := s.repo.Create(ctx, newNote)
 	if err != nil {
 		return &internal.Note{}, fmt.Errorf("note creation failed: %w", err)
 	}
+	s.metrics.NoteCreated(len(*createdNote.Content))
 
 	tz, err = stringToTimeZone(createdNote.ExpiresAtTimeZone)
 	if err != nil {
 		return &internal.Note{}, fmt.Errorf("note creation failed: %w", err)
 	}
 
-	encodedID := s.idEncDec.Encode(createdNote.ID)
+	encodedID, err := s.idEncDec.Encode(createdNote.ID)
+	if err != nil {
+		return &internal.Note{}, fmt.Errorf("note creation failed: %w", err)
+	}
+	span.SetAttributes(attribute.String("note.id", encodedID))
 	note.ID = encodedID
 	note.Content = createdNote.Content
 	note.CreatedAt = createdNote.CreatedAt
 	note.ExpiresIn = 0
 	note.ExpiresAt = createdNote.ExpiresAt
 	note.ExpiresAtTimeZone = tz
+	note.ViewsRemaining = createdNote.ViewsRemaining
 	return note, nil
 }
 
 func (s *NoteService) GetNote(ctx context.Context, id string, keyHash string) (*internal.Note, error) {
-	v := validator.New()
-	v.Check(len(id) == 12, "id should consist of 12 letters and/or digits and hyphens.")
-	v.Check(validator.ValidateHyphenatedB58String(id), "id should consist of latin letters and/or digits and hyphens")
-	v.Check(len(keyHash) == 44, "key hash should consist of 44 letters and/or digits")
-	v.Check(validator.ValidateHyphenatedB58String(keyHash), "key hash should consist of latin letters and/or digits")
-	if !v.CheckIsValid() {
-		var validationErrors []error
-		for _, err := range v.GetErrors() {
-			validationErrors = append(validationErrors, err)
+	ctx, span := s.tracer.Start(ctx, "NoteService.GetNote", trace.WithAttributes(attribute.String("note.id", id)))
+	note, err := s.viewNote(ctx, id, keyHash)
+	endSpan(span, err)
+	if err == nil {
+		s.metrics.NoteRead()
+	}
+	return note, err
+}
+
+// viewNote returns the note and takes one view of it. Once findNote has checked the key hash,
+// repo.View makes sure that concurrent readers are never handed out more views than the note has.
+// repo.View only takes the view findNote has seen, so the note is looked up again whenever another reader
+// has been faster. Since every such retry sees fewer views, it gives up once the views stop dropping.
+func (s *NoteService) viewNote(ctx context.Context, id string, keyHash string) (*internal.Note, error) {
+	noteDB, err := s.findNote(ctx, id, keyHash)
+	for err == nil {
+		viewedNote, viewErr := s.repo.View(ctx, noteDB.ID, noteDB.KeyHash, noteDB.ViewsRemaining)
+		if viewErr == nil {
+			return toNote(id, keyHash, viewedNote)
+		}
+		if !errors.Is(viewErr, internal.ErrNoteNotFound) {
+			return &internal.Note{}, fmt.Errorf("note viewing failed: %w", viewErr)
+		}
+		viewsRemaining := noteDB.ViewsRemaining
+		noteDB, err = s.findNote(ctx, id, keyHash)
+		if err == nil && noteDB.ViewsRemaining >= viewsRemaining {
+			err = ErrDoesNotExist
 		}
-		err := errors.Join(validationErrors...)
-		return &internal.Note{}, err
 	}
+	return &internal.Note{}, err
+}
 
-	gotError := false
-	decodedID, err := s.idEncDec.Decode(id)
+// GetNoteStatus looks the note up the same way GetNote does, but neither deletes it nor returns its content.
+func (s *NoteService) GetNoteStatus(ctx context.Context, id string, keyHash string) (*internal.Note, error) {
+	noteDB, err := s.findNote(ctx, id, keyHash)
 	if err != nil {
-		gotError = true
+		return &internal.Note{}, err
+	}
+	note, err := toNote(id, keyHash, noteDB)
+	if err != nil {
+		return note, err
 	}
+	note.Content = nil
+	return note, nil
+}
 
-	decodedKeyHash, err := base58.Decode(keyHash)
+// RequestReveal checks that the note exists and issues a token which has to be presented to RevealNote.
+// Unlike GetNote it is safe to call from link previews since the note is left intact.
+func (s *NoteService) RequestReveal(
+	ctx context.Context,
+	id string,
+	keyHash string,
+) (*internal.Note, *internal.RevealToken, error) {
+	note, err := s.GetNoteStatus(ctx, id, keyHash)
 	if err != nil {
-		gotError = true
+		return note, nil, err
 	}
+	return note, s.revealTokens.Issue(id, note.ViewsRemaining, time.Now()), nil
+}
 
-	noteDB, err := s.repo.Get(ctx, decodedID)
+// RevealNote returns the note and burns it if the reveal token issued for the note is still valid.
+// The token is bound to the views the note had left when it was issued, so it cannot be used again
+// once the note has been viewed.
+func (s *NoteService) RevealNote(ctx context.Context, id string, keyHash string, token string) (*internal.Note, error) {
+	ctx, span := s.tracer.Start(ctx, "NoteService.RevealNote", trace.WithAttributes(attribute.String("note.id", id)))
+	note, err := s.revealNote(ctx, id, keyHash, token)
+	endSpan(span, err)
+	if err == nil {
+		s.metrics.NoteRead()
+	}
+	return note, err
+}
+
+func (s *NoteService) revealNote(ctx context.Context, id string, keyHash string, token string) (*internal.Note, error) {
+	noteDB, err := s.findNote(ctx, id, keyHash)
 	if err != nil {
-		gotError = true
+		return &internal.Note{}, err
+	}
+	if err := s.revealTokens.Verify(token, id, noteDB.ViewsRemaining, time.Now()); err != nil {
+		return &internal.Note{}, err
 	}
+	viewedNote, err := s.repo.View(ctx, noteDB.ID, noteDB.KeyHash, noteDB.ViewsRemaining)
+	if errors.Is(err, internal.ErrNoteNotFound) {
+		// Another reader has taken the view the token was issued for.
+		return &internal.Note{}, ErrInvalidRevealToken
+	}
+	if err != nil {
+		return &internal.Note{}, fmt.Errorf("note viewing failed: %w", err)
+	}
+	return toNote(id, keyHash, viewedNote)
+}
 
-	var noteKeyHash []byte
-	var noteTimeZone string
+// findNote returns the stored note if it exists, has not expired and keyHash is its key hash.
+// Key hashes are compared in constant time, also for notes which do not exist. Only a key hash which does not
+// match a note which can still be viewed counts as a failed attempt. A lookup of a note which cannot be viewed
+// records an attempt for unassignedNoteID instead, so that it costs the same storage write and response times
+// do not tell which notes exist. Expired notes are deleted once keyHash has been checked.
+// Storage failures are returned as they are rather than as ErrDoesNotExist.
+func (s *NoteService) findNote(ctx context.Context, id string, keyHash string) (*internal.NoteModel, error) {
+	if err := s.checkNoteCredentials(id, keyHash); err != nil {
+		return nil, err
+	}
+
+	var noteDB *internal.NoteModel
+	decodedID, err := s.idEncDec.Decode(id)
+	if err == nil {
+		noteDB, err = s.repo.Get(ctx, decodedID)
+		if err != nil && !errors.Is(err, internal.ErrNoteNotFound) {
+			return nil, fmt.Errorf("note retrieving failed: %w", err)
+		}
+	}
+
+	decodedKeyHash, err := base58.Decode(keyHash)
+	if err != nil {
+		decodedKeyHash = nil
+	}
+	noteKeyHash := decodedKeyHash
 	if noteDB != nil {
 		noteKeyHash = noteDB.KeyHash
-		noteTimeZone = noteDB.ExpiresAtTimeZone
-	} else {
-		noteKeyHash = decodedKeyHash
 	}
-	isAuthorized := compareKeyHashes(decodedKeyHash, noteKeyHash)
+	isAuthorized := compareKeyHashes(decodedKeyHash, noteKeyHash) && decodedKeyHash != nil
 
-	tz, err := stringToTimeZone(noteTimeZone)
-	if err != nil {
-		return &internal.Note{}, errors.New("note has invalid time zone")
+	if noteDB == nil || noteDB.ViewsRemaining == 0 {
+		if err := s.recordFailedAttempt(ctx, unassignedNoteID); err != nil {
+			return nil, err
+		}
+		return nil, ErrDoesNotExist
+	}
+	if !isAuthorized {
+		if err := s.recordFailedAttempt(ctx, noteDB.ID); err != nil {
+			return nil, err
+		}
+		return nil, ErrDoesNotExist
 	}
 
-	err = s.repo.Delete(ctx, decodedID)
+	tz, err := stringToTimeZone(noteDB.ExpiresAtTimeZone)
 	if err != nil {
-		gotError = true
+		return nil, errors.New("note has invalid time zone")
+	}
+	if !time.Now().Before(restoreExpirationDate(noteDB.ExpiresAt, tz)) {
+		// The note is deleted right away rather than left to the reaper, so that nobody can view it
+		// between its expiry and the next reap.
+		if err = s.repo.Delete(ctx, noteDB.ID); err != nil {
+			return nil, fmt.Errorf("expired note deletion failed: %w", err)
+		}
+		s.metrics.NotesExpired(1)
+		return nil, ErrDoesNotExist
 	}
+	return noteDB, nil
+}
 
-	if !isAuthorized || gotError {
-		return &internal.Note{}, ErrDoesNotExist
+func toNote(id string, keyHash string, noteDB *internal.NoteModel) (*internal.Note, error) {
+	tz, err := stringToTimeZone(noteDB.ExpiresAtTimeZone)
+	if err != nil {
+		return &internal.Note{}, errors.New("note has invalid time zone")
 	}
 	return &internal.Note{
 		ID:                id,
 		Content:           noteDB.Content,
 		CreatedAt:         noteDB.CreatedAt,
-		ExpiresAt:         noteDB.ExpiresAt,
+		ExpiresAt:         restoreExpirationDate(noteDB.ExpiresAt, tz),
 		ExpiresAtTimeZone: tz,
 		KeyHash:           keyHash,
+		ViewsRemaining:    noteDB.ViewsRemaining,
 	}, nil
 }
 
-func calcExpirationDate(expiresAt time.Time, tz *time.Location, expiresIn time.Duration) (time.Time, *time.Location) {
-	if expiresIn != 0 {
-		exp := time.Now().UTC().Add(expiresIn)
-		return exp, time.UTC
-	}
-	return expiresAt.UTC(), tz
-}
-
-func stringToTimeZone(tzID string) (*time.Location, error) {
-	tz, err := time.LoadLocation(tzID)
+func (s *NoteService) DeleteNote(ctx context.Context, id string, keyHash string) error {
+	noteDB, err := s.findNote(ctx, id, keyHash)
 	if err != nil {
-		return tz, fmt.Errorf("unable to convert tz ID to time zone: %w", err)
+		return err
 	}
-	return tz, nil
-}
-
-type B58IDEncDec struct{}
-
-func (ed *B58IDEncDec) Encode(id uint64) string {
-	bin := make([]byte, 8)
-	binary.BigEndian.PutUint64(bin, id)
-	enc := base58.Encode(bin)
-	enc = ed.padEncodedID(enc)
-	return insertStringSeparator(enc, "-", 4)
-}
-
-func (ed *B58IDEncDec) padEncodedID(id string) string {
-	if width := 10 - len(id); width > 0 {
-		return padStringWith(id, "1", 10)
+	if err = s.repo.Delete(ctx, noteDB.ID); err != nil {
+		return fmt.Errorf("note deletion failed: %w", err)
 	}
-	return id
+	return nil
 }
 
-func (ed *B58IDEncDec) Decode(str string) (uint64, error) {
-	str, err := removeStringSeparator(str, "-", 4)
-	if err != nil {
-		return 0, err
+// recordFailedAttempt counts a wrong key hash presented for the note and burns the note
+// once the limit is reached. Nothing is recorded if the note has been deleted in the meantime.
+func (s *NoteService) recordFailedAttempt(ctx context.Context, id uint64) error {
+	attempts, err := s.repo.RecordFailedAttempt(ctx, id)
+	if errors.Is(err, internal.ErrNoteNotFound) {
+		return nil
 	}
-	dec, err := base58.Decode(str)
 	if err != nil {
-		return 0, fmt.Errorf("id decoding error: %w", err)
+		return fmt.Errorf("failed attempt recording failed: %w", err)
+	}
+	maxFailedAttempts := uint(s.maxFailedAttempts.Load())
+	if attempts < maxFailedAttempts {
+		return nil
 	}
-	dec = dec[len(dec)-8:]
-	num := binary.BigEndian.Uint64(dec)
-	return num, nil
+	if err = s.repo.Delete(ctx, id); err != nil {
+		return fmt.Errorf("locked out note deletion failed: %w", err)
+	}
+	if attempts != maxFailedAttempts {
+		return nil
+	}
+	s.metrics.NoteLockedOut()
+	if s.onLockout != nil {
+		s.onLockout(ctx, attempts)
+	}
+	return nil
 }
 
-func compareKeyHashes(x, y []byte) bool {
-	return subtle.ConstantTimeCompare(x, y) == 1
+// checkNoteCredentials leaves the pointers of its errors empty. The note ID is taken from the path and the key hash
+// mostly from the query, so it is up to callers reading the key hash from a request body to point its errors there.
+func (s *NoteService) checkNoteCredentials(id string, keyHash string) error {
+	v := validator.New()
+	v.CheckField(s.idEncDec.Validate(id), "", internal.CodeNoteIDInvalid, "id has invalid format")
+	v.CheckField(
+		len(keyHash) == 44, "", internal.CodeKeyHashInvalid,
+		"key hash should consist of 44 letters and/or digits",
+	)
+	v.CheckField(
+		validator.ValidateHyphenatedB58String(keyHash), "", internal.CodeKeyHashInvalid,
+		"key hash should consist of latin letters and/or digits",
+	)
+	if !v.CheckIsValid() {
+		var validationErrors []error
+		for _, err := range v.GetErrors() {
+			validationErrors = append(validationErrors, err)
+		}
+		return errors.Join(validationErrors...)
+	}
+	return nil
 }
 
-func padStringWith(s, padding string, totalWidth int) string {
-	stringWidth := utf8.RuneCountInString(s)
-	paddingWidth := utf8.RuneCountInString(padding)
-	fillWidth := totalWidth - stringWidth
-	if fillWidth > 0 {
-		fill := strings.Repeat(padding, fillWidth/paddingWidth) + padding[:fillWidth%paddingWidth]
-		s = fill + s
+// endSpan marks the span as failed unless err is caused by the client. The error message is left out
+// since errors of the repository may quote the values they failed on.
+func endSpan(span trace.Span, err error) {
+	var validationError validator.ValidationError
+	if err != nil && !errors.Is(err, ErrDoesNotExist) && !errors.As(err, &validationError) {
+		span.SetStatus(codes.Error, "")
 	}
-	return s
+	span.End()
 }
 
-func insertStringSeparator(s, sep string, segmentWidth int) string {
-	approxSepCount := len(s) / segmentWidth
-	if len(s)%segmentWidth == 0 {
-		approxSepCount--
+func calcExpirationDate(expiresAt time.Time, tz *time.Location, expiresIn time.Duration) (time.Time, *time.Location) {
+	if expiresIn != 0 {
+		exp := time.Now().UTC().Add(expiresIn)
+		return exp, time.UTC
 	}
-	newWidth := len(s) + approxSepCount*len(sep)
-	strBytes := make([]byte, newWidth)
+	return expiresAt.UTC(), tz
+}
 
-	writeI := 0
-	for byteI, charI := 0, 0; byteI < len(s)-1; byteI++ {
-		strBytes[writeI] = s[byteI]
-		writeI++
+// restoreExpirationDate is the inverse of calcExpirationDate: the stored date is a UTC wall clock
+// which is converted back to the note's time zone.
+func restoreExpirationDate(storedExpiresAt time.Time, tz *time.Location) time.Time {
+	return datetime.TimeAsLocalTime(storedExpiresAt, time.UTC).In(tz)
+}
 
-		if utf8.RuneStart(s[byteI+1]) {
-			charI++
-		}
-		if charI == segmentWidth {
-			for i := 0; i < len(sep); i++ {
-				strBytes[writeI] = sep[i]
-				writeI++
-			}
-			charI = 0
-		}
+func stringToTimeZone(tzID string) (*time.Location, error) {
+	tz, err := time.LoadLocation(tzID)
+	if err != nil {
+		return tz, fmt.Errorf("unable to convert tz ID to time zone: %w", err)
 	}
-	strBytes[writeI] = s[len(s)-1]
-	return string(strBytes[:writeI+1])
+	return tz, nil
 }
 
-func removeStringSeparator(s, sep string, segmentWidth int) (string, error) {
-	strBytes := make([]byte, len(s))
-
-	writeI := 0
-	for byteI, charI := 0, 0; byteI < len(s); byteI++ {
-		if byteI == len(s)-1 || utf8.RuneStart(s[byteI+1]) {
-			charI++
-		}
-
-		strBytes[writeI] = s[byteI]
-		writeI++
-
-		if charI == segmentWidth && byteI != len(s)-1 {
-			charI = 0
-			substr := s[byteI+1 : byteI+1+len(sep)]
-			if substr != sep {
-				return "", fmt.Errorf(
-					"removeStringSeparator: expected %s at s[%d], but got %s", sep, byteI+1, substr,
-				)
-			}
-			byteI += len(sep)
-		}
-	}
-	return string(strBytes[:writeI]), nil
+func compareKeyHashes(x, y []byte) bool {
+	return subtle.ConstantTimeCompare(x, y) == 1
 }
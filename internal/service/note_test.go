@@ -0,0 +1,416 @@
+package service
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"github.com/ledorub/snote-api/internal"
+	"github.com/ledorub/snote-api/internal/db/memory"
+	"github.com/ledorub/snote-api/internal/encdec"
+	"github.com/ledorub/snote-api/internal/validator"
+	"github.com/mr-tron/base58"
+	"io"
+	"log/slog"
+	"sync"
+	"testing"
+	"time"
+)
+
+func newTestNoteService(t *testing.T) *NoteService {
+	t.Helper()
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+	revealTokens := NewRevealTokens([]byte("secret"), time.Minute)
+	return New(logger, memory.NewNoteRepository(logger), encdec.DefaultIDFormat(), revealTokens)
+}
+
+func encodeTestID(t *testing.T, id uint64) string {
+	t.Helper()
+	encoded, err := encdec.DefaultIDFormat().Encode(id)
+	if err != nil {
+		t.Fatalf("Encode() error = %v", err)
+	}
+	return encoded
+}
+
+func createTestNote(t *testing.T, s *NoteService, keyHash string, maxViews uint) *internal.Note {
+	t.Helper()
+	content := "content"
+	note, err := internal.NewNote(&content, time.Hour, time.Time{}, "UTC", keyHash, maxViews)
+	if err != nil {
+		t.Fatalf("NewNote() error = %v", err)
+	}
+	note, err = s.CreateNote(context.Background(), note)
+	if err != nil {
+		t.Fatalf("CreateNote() error = %v", err)
+	}
+	return note
+}
+
+func TestGetNoteConcurrently(t *testing.T) {
+	const readers = 50
+	keyHash := base58.Encode(bytes.Repeat([]byte{0xff}, 32))
+
+	tests := []struct {
+		name     string
+		maxViews uint
+	}{
+		{name: "single view", maxViews: 1},
+		{name: "multiple views", maxViews: 3},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := newTestNoteService(t)
+			note := createTestNote(t, s, keyHash, tt.maxViews)
+
+			var (
+				wg        sync.WaitGroup
+				mu        sync.Mutex
+				served    uint
+				notExists uint
+			)
+			start := make(chan struct{})
+			for i := 0; i < readers; i++ {
+				wg.Add(1)
+				go func() {
+					defer wg.Done()
+					<-start
+					_, err := s.GetNote(context.Background(), note.ID, keyHash)
+
+					mu.Lock()
+					defer mu.Unlock()
+					switch {
+					case err == nil:
+						served++
+					case errors.Is(err, ErrDoesNotExist):
+						notExists++
+					default:
+						t.Errorf("GetNote() error = %v, want nil or %v", err, ErrDoesNotExist)
+					}
+				}()
+			}
+			close(start)
+			wg.Wait()
+
+			if served != tt.maxViews {
+				t.Errorf("note served %d times, want %d", served, tt.maxViews)
+			}
+			if want := readers - tt.maxViews; notExists != want {
+				t.Errorf("GetNote() returned %v %d times, want %d", ErrDoesNotExist, notExists, want)
+			}
+		})
+	}
+}
+
+func TestRevealNoteRejectsReplayedToken(t *testing.T) {
+	keyHash := base58.Encode(bytes.Repeat([]byte{0xff}, 32))
+	s := newTestNoteService(t)
+	note := createTestNote(t, s, keyHash, 3)
+
+	_, token, err := s.RequestReveal(context.Background(), note.ID, keyHash)
+	if err != nil {
+		t.Fatalf("RequestReveal() error = %v", err)
+	}
+	if _, err := s.RevealNote(context.Background(), note.ID, keyHash, token.Value); err != nil {
+		t.Fatalf("RevealNote() error = %v", err)
+	}
+	if _, err := s.RevealNote(context.Background(), note.ID, keyHash, token.Value); !errors.Is(err, ErrInvalidRevealToken) {
+		t.Errorf("RevealNote() with a used token error = %v, want %v", err, ErrInvalidRevealToken)
+	}
+
+	_, token, err = s.RequestReveal(context.Background(), note.ID, keyHash)
+	if err != nil {
+		t.Fatalf("RequestReveal() error = %v", err)
+	}
+	revealed, err := s.RevealNote(context.Background(), note.ID, keyHash, token.Value)
+	if err != nil {
+		t.Fatalf("RevealNote() with a new token error = %v", err)
+	}
+	if revealed.ViewsRemaining != 1 {
+		t.Errorf("ViewsRemaining = %d, want 1", revealed.ViewsRemaining)
+	}
+}
+
+// keepingNoteRepository hands out its note until it is deleted, whether it has expired or not,
+// like the SQL storages do.
+type keepingNoteRepository struct {
+	noteRepository
+	note    *internal.NoteModel
+	deleted bool
+}
+
+func (r *keepingNoteRepository) Get(ctx context.Context, id uint64) (*internal.NoteModel, error) {
+	if r.deleted || id != r.note.ID {
+		return nil, internal.ErrNoteNotFound
+	}
+	note := *r.note
+	return &note, nil
+}
+
+func (r *keepingNoteRepository) Delete(ctx context.Context, id uint64) error {
+	if id == r.note.ID {
+		r.deleted = true
+	}
+	return nil
+}
+
+func TestGetNoteDeletesExpiredNote(t *testing.T) {
+	keyHash := bytes.Repeat([]byte{0xff}, 32)
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+	content := "content"
+	repo := &keepingNoteRepository{note: &internal.NoteModel{
+		ID:                1,
+		Content:           &content,
+		CreatedAt:         time.Now().Add(-2 * time.Hour),
+		ExpiresAt:         time.Now().Add(-time.Hour).UTC(),
+		ExpiresAtTimeZone: "UTC",
+		KeyHash:           keyHash,
+		ViewsRemaining:    2,
+	}}
+	s := New(logger, repo, encdec.DefaultIDFormat(), NewRevealTokens([]byte("secret"), time.Minute))
+
+	_, err := s.GetNote(context.Background(), encodeTestID(t, repo.note.ID), base58.Encode(keyHash))
+	if !errors.Is(err, ErrDoesNotExist) {
+		t.Errorf("GetNote() error = %v, want %v", err, ErrDoesNotExist)
+	}
+	if !repo.deleted {
+		t.Error("expired note was not deleted")
+	}
+}
+
+// failingNoteRepository fails to look notes up as if the storage was unreachable.
+type failingNoteRepository struct {
+	noteRepository
+}
+
+var errStorageUnavailable = errors.New("storage unavailable")
+
+func (failingNoteRepository) Get(context.Context, uint64) (*internal.NoteModel, error) {
+	return nil, fmt.Errorf("retrieving failed: %w", errStorageUnavailable)
+}
+
+func TestGetNotePropagatesStorageErrors(t *testing.T) {
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+	repo := failingNoteRepository{memory.NewNoteRepository(logger)}
+	s := New(logger, repo, encdec.DefaultIDFormat(), NewRevealTokens([]byte("secret"), time.Minute))
+
+	keyHash := base58.Encode(bytes.Repeat([]byte{0xff}, 32))
+	_, err := s.GetNote(context.Background(), encodeTestID(t, 1), keyHash)
+	if !errors.Is(err, errStorageUnavailable) {
+		t.Errorf("GetNote() error = %v, want %v", err, errStorageUnavailable)
+	}
+}
+
+func TestGetNoteLockout(t *testing.T) {
+	const maxFailedAttempts = 3
+	keyHash := base58.Encode(bytes.Repeat([]byte{0xff}, 32))
+	wrongKeyHash := base58.Encode(bytes.Repeat([]byte{0xfe}, 32))
+
+	tests := []struct {
+		name        string
+		attempts    int
+		wantLockout bool
+	}{
+		{name: "below limit", attempts: maxFailedAttempts - 1, wantLockout: false},
+		{name: "at limit", attempts: maxFailedAttempts, wantLockout: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+			var lockouts int
+			s := New(
+				logger,
+				memory.NewNoteRepository(logger),
+				encdec.DefaultIDFormat(),
+				NewRevealTokens([]byte("secret"), time.Minute),
+				WithLockout(maxFailedAttempts, func(context.Context, uint) { lockouts++ }),
+			)
+			note := createTestNote(t, s, keyHash, 1)
+
+			for i := 0; i < tt.attempts; i++ {
+				if _, err := s.GetNote(context.Background(), note.ID, wrongKeyHash); !errors.Is(err, ErrDoesNotExist) {
+					t.Fatalf("GetNote() with a wrong key hash error = %v, want %v", err, ErrDoesNotExist)
+				}
+			}
+			_, err := s.GetNote(context.Background(), note.ID, keyHash)
+			if isLockedOut := errors.Is(err, ErrDoesNotExist); isLockedOut != tt.wantLockout {
+				t.Errorf("GetNote() error = %v, want locked out %v", err, tt.wantLockout)
+			}
+			wantLockouts := 0
+			if tt.wantLockout {
+				wantLockouts = 1
+			}
+			if lockouts != wantLockouts {
+				t.Errorf("lockout handler called %d times, want %d", lockouts, wantLockouts)
+			}
+		})
+	}
+}
+
+func TestGetNoteExhaustedNoteIsNoFailedAttempt(t *testing.T) {
+	keyHash := bytes.Repeat([]byte{0xff}, 32)
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+	repo := memory.NewNoteRepository(logger)
+	s := New(logger, repo, encdec.DefaultIDFormat(), NewRevealTokens([]byte("secret"), time.Minute))
+
+	content := "content"
+	exhaustedNote, err := repo.Create(context.Background(), &internal.NoteModel{
+		Content:           &content,
+		CreatedAt:         time.Now(),
+		ExpiresAt:         time.Now().Add(time.Hour).UTC(),
+		ExpiresAtTimeZone: "UTC",
+		KeyHash:           keyHash,
+		ViewsRemaining:    0,
+	})
+	if err != nil {
+		t.Fatalf("Create() error = %v", err)
+	}
+
+	wrongKeyHash := base58.Encode(bytes.Repeat([]byte{0xfe}, 32))
+	_, err = s.GetNote(context.Background(), encodeTestID(t, exhaustedNote.ID), wrongKeyHash)
+	if !errors.Is(err, ErrDoesNotExist) {
+		t.Errorf("GetNote() error = %v, want %v", err, ErrDoesNotExist)
+	}
+	attempts, err := repo.RecordFailedAttempt(context.Background(), exhaustedNote.ID)
+	if err != nil {
+		t.Fatalf("RecordFailedAttempt() error = %v", err)
+	}
+	if attempts != 1 {
+		t.Errorf("failed attempts = %d, want none recorded by GetNote", attempts-1)
+	}
+}
+
+// countingNoteRepository counts the failed attempts recorded, whether a note matches or not.
+type countingNoteRepository struct {
+	noteRepository
+	recorded int
+}
+
+func (r *countingNoteRepository) RecordFailedAttempt(ctx context.Context, id uint64) (uint, error) {
+	r.recorded++
+	return r.noteRepository.RecordFailedAttempt(ctx, id)
+}
+
+func TestGetNoteMissingNoteCostsFailedAttempt(t *testing.T) {
+	keyHash := base58.Encode(bytes.Repeat([]byte{0xff}, 32))
+	wrongKeyHash := base58.Encode(bytes.Repeat([]byte{0xfe}, 32))
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+	repo := &countingNoteRepository{noteRepository: memory.NewNoteRepository(logger)}
+	s := New(logger, repo, encdec.DefaultIDFormat(), NewRevealTokens([]byte("secret"), time.Minute))
+	note := createTestNote(t, s, keyHash, 1)
+
+	tests := []struct {
+		name    string
+		id      string
+		keyHash string
+	}{
+		{name: "wrong key hash", id: note.ID, keyHash: wrongKeyHash},
+		{name: "missing note", id: encodeTestID(t, 2), keyHash: keyHash},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			repo.recorded = 0
+			if _, err := s.GetNote(context.Background(), tt.id, tt.keyHash); !errors.Is(err, ErrDoesNotExist) {
+				t.Fatalf("GetNote() error = %v, want %v", err, ErrDoesNotExist)
+			}
+			if repo.recorded != 1 {
+				t.Errorf("failed attempts recorded %d times, want 1", repo.recorded)
+			}
+		})
+	}
+	if _, err := s.GetNote(context.Background(), note.ID, keyHash); err != nil {
+		t.Errorf("GetNote() error = %v", err)
+	}
+}
+
+func TestSetNoteLimits(t *testing.T) {
+	keyHash := base58.Encode(bytes.Repeat([]byte{0xff}, 32))
+	content := "content"
+	s := newTestNoteService(t)
+
+	note, err := internal.NewNote(&content, 5*time.Minute, time.Time{}, "UTC", keyHash, 1)
+	if err != nil {
+		t.Fatalf("NewNote() error = %v", err)
+	}
+	if _, err := s.CreateNote(context.Background(), note); err == nil {
+		t.Fatal("CreateNote() with an expiration timeout below the default limit succeeded")
+	}
+
+	s.SetNoteLimits(internal.NoteLimits{MinExpiresIn: time.Minute, MaxExpiresIn: time.Hour, MaxViews: 1})
+	note, err = internal.NewNote(&content, 5*time.Minute, time.Time{}, "UTC", keyHash, 1)
+	if err != nil {
+		t.Fatalf("NewNote() error = %v", err)
+	}
+	if _, err := s.CreateNote(context.Background(), note); err != nil {
+		t.Errorf("CreateNote() error = %v", err)
+	}
+}
+
+func TestSetNoteLimitsMaxViews(t *testing.T) {
+	keyHash := base58.Encode(bytes.Repeat([]byte{0xff}, 32))
+	content := "content"
+	s := newTestNoteService(t)
+	limits := internal.DefaultNoteLimits()
+	limits.MaxViews = 20
+	s.SetNoteLimits(limits)
+
+	note, err := internal.NewNote(&content, time.Hour, time.Time{}, "UTC", keyHash, 20)
+	if err != nil {
+		t.Fatalf("NewNote() error = %v", err)
+	}
+	if _, err := s.CreateNote(context.Background(), note); err != nil {
+		t.Errorf("CreateNote() error = %v", err)
+	}
+	note, err = internal.NewNote(&content, time.Hour, time.Time{}, "UTC", keyHash, 21)
+	if err != nil {
+		t.Fatalf("NewNote() error = %v", err)
+	}
+	if _, err := s.CreateNote(context.Background(), note); err == nil {
+		t.Error("CreateNote() with more views than allowed succeeded")
+	}
+}
+
+func TestGetNoteInvalidKeyHash(t *testing.T) {
+	s := newTestNoteService(t)
+	_, err := s.GetNote(context.Background(), encodeTestID(t, 1), "invalid")
+
+	validationErrors := validator.Errors(err)
+	if len(validationErrors) == 0 {
+		t.Fatalf("GetNote() error = %v, want validation errors", err)
+	}
+	for _, validationError := range validationErrors {
+		if validationError.Pointer != "" || validationError.Code != internal.CodeKeyHashInvalid {
+			t.Errorf("GetNote() error = %+v, want %q without a pointer", validationError, internal.CodeKeyHashInvalid)
+		}
+	}
+}
+
+// failingViewNoteRepository finds notes but fails to view them as if the storage went away in between.
+type failingViewNoteRepository struct {
+	noteRepository
+}
+
+func (failingViewNoteRepository) View(context.Context, uint64, []byte, uint) (*internal.NoteModel, error) {
+	return nil, fmt.Errorf("viewing failed: %w", errStorageUnavailable)
+}
+
+func TestViewPropagatesStorageErrors(t *testing.T) {
+	keyHash := base58.Encode(bytes.Repeat([]byte{0xff}, 32))
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+	repo := memory.NewNoteRepository(logger)
+	s := New(logger, repo, encdec.DefaultIDFormat(), NewRevealTokens([]byte("secret"), time.Minute))
+	note := createTestNote(t, s, keyHash, 1)
+	_, token, err := s.RequestReveal(context.Background(), note.ID, keyHash)
+	if err != nil {
+		t.Fatalf("RequestReveal() error = %v", err)
+	}
+
+	s.repo = failingViewNoteRepository{repo}
+	if _, err := s.GetNote(context.Background(), note.ID, keyHash); !errors.Is(err, errStorageUnavailable) {
+		t.Errorf("GetNote() error = %v, want %v", err, errStorageUnavailable)
+	}
+	_, err = s.RevealNote(context.Background(), note.ID, keyHash, token.Value)
+	if !errors.Is(err, errStorageUnavailable) {
+		t.Errorf("RevealNote() error = %v, want %v", err, errStorageUnavailable)
+	}
+}
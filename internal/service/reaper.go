@@ -0,0 +1,110 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"sync/atomic"
+	"time"
+)
+
+const (
+	defaultReapInterval  = 1 * time.Minute
+	defaultReapBatchSize = 1000
+)
+
+type expiredNoteRepository interface {
+	DeleteExpired(ctx context.Context, before time.Time, limit uint64) (uint64, error)
+}
+
+// Reaper periodically purges notes whose expiration date has passed.
+type Reaper struct {
+	logger    *slog.Logger
+	repo      expiredNoteRepository
+	interval  atomic.Int64
+	batchSize atomic.Uint64
+	metrics   Metrics
+}
+
+func NewReaper(
+	logger *slog.Logger,
+	repo expiredNoteRepository,
+	interval time.Duration,
+	batchSize uint64,
+	opts ...ReaperOpt,
+) *Reaper {
+	r := &Reaper{logger: logger, repo: repo, metrics: noopMetrics{}}
+	r.interval.Store(int64(defaultReapInterval))
+	r.batchSize.Store(defaultReapBatchSize)
+	r.Configure(interval, batchSize)
+	for _, opt := range opts {
+		opt(r)
+	}
+	return r
+}
+
+type ReaperOpt func(r *Reaper)
+
+func WithReaperMetrics(metrics Metrics) ReaperOpt {
+	return func(r *Reaper) {
+		r.metrics = metrics
+	}
+}
+
+// Configure changes the settings of a running reaper. A new interval takes effect after the next tick.
+// Zero values are ignored.
+func (r *Reaper) Configure(interval time.Duration, batchSize uint64) {
+	if interval > 0 {
+		r.interval.Store(int64(interval))
+	}
+	if batchSize > 0 {
+		r.batchSize.Store(batchSize)
+	}
+}
+
+// Run reaps expired notes every interval until ctx is done.
+func (r *Reaper) Run(ctx context.Context) {
+	interval := time.Duration(r.interval.Load())
+	r.logger.Info("reaper: started", "interval", interval.String(), "batch_size", r.batchSize.Load())
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			r.logger.Info("reaper: stopped")
+			return
+		case <-ticker.C:
+			reaped, err := r.Reap(ctx)
+			// A batch interrupted by shutdown is not a failure. The reaper stops on the next iteration.
+			if err != nil && ctx.Err() == nil {
+				r.logger.Error("reaper: reaping failed", "error", err)
+			}
+			if reaped > 0 {
+				r.logger.Info("reaper: reaped expired notes", "count", reaped)
+			}
+			if newInterval := time.Duration(r.interval.Load()); newInterval != interval {
+				interval = newInterval
+				ticker.Reset(interval)
+			}
+		}
+	}
+}
+
+// Reap deletes expired notes in batches until none are left and returns their count.
+func (r *Reaper) Reap(ctx context.Context) (uint64, error) {
+	now := time.Now().UTC()
+	batchSize := r.batchSize.Load()
+	var total uint64
+	for {
+		deleted, err := r.repo.DeleteExpired(ctx, now, batchSize)
+		total += deleted
+		r.metrics.NotesExpired(deleted)
+		if err != nil {
+			return total, fmt.Errorf("reaping failed: %w", err)
+		}
+		if deleted < batchSize || ctx.Err() != nil {
+			return total, nil
+		}
+	}
+}
@@ -0,0 +1,61 @@
+package service
+
+import (
+	"bytes"
+	"context"
+	"log/slog"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// blockingNoteRepository blocks every deletion until its context is done, like a query cancelled by shutdown.
+type blockingNoteRepository struct {
+	started chan struct{}
+	once    sync.Once
+}
+
+func (r *blockingNoteRepository) DeleteExpired(ctx context.Context, _ time.Time, _ uint64) (uint64, error) {
+	r.once.Do(func() { close(r.started) })
+	<-ctx.Done()
+	return 0, ctx.Err()
+}
+
+func TestReaperStopsCleanlyWhenCancelled(t *testing.T) {
+	var logs bytes.Buffer
+	var logsMu sync.Mutex
+	logger := slog.New(slog.NewTextHandler(&lockedWriter{mu: &logsMu, w: &logs}, nil))
+	repo := &blockingNoteRepository{started: make(chan struct{})}
+	reaper := NewReaper(logger, repo, time.Millisecond, 10)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		reaper.Run(ctx)
+	}()
+	<-repo.started
+	cancel()
+	<-done
+
+	logsMu.Lock()
+	defer logsMu.Unlock()
+	if strings.Contains(logs.String(), "level=ERROR") {
+		t.Errorf("reaper logged an error on shutdown:\n%s", logs.String())
+	}
+	if !strings.Contains(logs.String(), "reaper: stopped") {
+		t.Errorf("reaper did not log that it stopped:\n%s", logs.String())
+	}
+}
+
+type lockedWriter struct {
+	mu *sync.Mutex
+	w  *bytes.Buffer
+}
+
+func (w *lockedWriter) Write(p []byte) (int, error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return w.w.Write(p)
+}
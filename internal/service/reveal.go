@@ -0,0 +1,71 @@
+package service
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/binary"
+	"errors"
+	"github.com/ledorub/snote-api/internal"
+	"github.com/mr-tron/base58"
+	"sync/atomic"
+	"time"
+)
+
+const defaultRevealTokenTTL = 5 * time.Minute
+
+var ErrInvalidRevealToken = errors.New("invalid reveal token")
+
+// RevealTokens issues and verifies short-lived tokens that allow revealing a specific note.
+// A token is the base58 encoded expiration timestamp followed by an HMAC-SHA256 of the timestamp, the views the note
+// had left when the token was issued and the note ID. Revealing the note takes one of those views, so every token
+// can be used once.
+type RevealTokens struct {
+	secret []byte
+	ttl    atomic.Int64
+}
+
+func NewRevealTokens(secret []byte, ttl time.Duration) *RevealTokens {
+	t := &RevealTokens{secret: secret}
+	t.ttl.Store(int64(defaultRevealTokenTTL))
+	t.SetTTL(ttl)
+	return t
+}
+
+// SetTTL changes the lifetime of the tokens issued from now on. Non-positive values are ignored.
+func (t *RevealTokens) SetTTL(ttl time.Duration) {
+	if ttl > 0 {
+		t.ttl.Store(int64(ttl))
+	}
+}
+
+func (t *RevealTokens) Issue(noteID string, viewsRemaining uint, now time.Time) *internal.RevealToken {
+	expiresAt := now.Add(time.Duration(t.ttl.Load())).Truncate(time.Second)
+	bin := make([]byte, 8, 8+sha256.Size)
+	binary.BigEndian.PutUint64(bin, uint64(expiresAt.Unix()))
+	bin = append(bin, t.sign(noteID, viewsRemaining, bin)...)
+	return &internal.RevealToken{Value: base58.Encode(bin), ExpiresAt: expiresAt}
+}
+
+func (t *RevealTokens) Verify(token string, noteID string, viewsRemaining uint, now time.Time) error {
+	bin, err := base58.Decode(token)
+	if err != nil || len(bin) != 8+sha256.Size {
+		return ErrInvalidRevealToken
+	}
+	expiry, signature := bin[:8], bin[8:]
+	if !hmac.Equal(signature, t.sign(noteID, viewsRemaining, expiry)) {
+		return ErrInvalidRevealToken
+	}
+	expiresAt := time.Unix(int64(binary.BigEndian.Uint64(expiry)), 0)
+	if !now.Before(expiresAt) {
+		return ErrInvalidRevealToken
+	}
+	return nil
+}
+
+func (t *RevealTokens) sign(noteID string, viewsRemaining uint, expiry []byte) []byte {
+	mac := hmac.New(sha256.New, t.secret)
+	mac.Write(expiry)
+	mac.Write(binary.BigEndian.AppendUint64(nil, uint64(viewsRemaining)))
+	mac.Write([]byte(noteID))
+	return mac.Sum(nil)
+}
@@ -0,0 +1,52 @@
+package tracing
+
+import (
+	"context"
+	"fmt"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp"
+	"go.opentelemetry.io/otel/exporters/stdout/stdouttrace"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	semconv "go.opentelemetry.io/otel/semconv/v1.26.0"
+	"net/url"
+)
+
+const (
+	serviceName = "snote-api"
+	// defaultOTLPPath is where OTLP/HTTP collectors accept spans.
+	defaultOTLPPath = "/v1/traces"
+)
+
+// NewProvider creates a provider batching spans to the exporter. It should be shut down to flush pending spans.
+func NewProvider(exporter sdktrace.SpanExporter) *sdktrace.TracerProvider {
+	return sdktrace.NewTracerProvider(
+		sdktrace.WithBatcher(exporter),
+		sdktrace.WithResource(resource.NewSchemaless(semconv.ServiceName(serviceName))),
+	)
+}
+
+// NewOTLPExporter creates an exporter sending spans to the OTLP/HTTP collector at endpoint.
+// Spans are sent to /v1/traces unless the endpoint has a path.
+func NewOTLPExporter(ctx context.Context, endpoint string) (sdktrace.SpanExporter, error) {
+	u, err := url.Parse(endpoint)
+	if err != nil {
+		return nil, fmt.Errorf("OTLP exporter: invalid endpoint: %w", err)
+	}
+	if u.Path == "" || u.Path == "/" {
+		u.Path = defaultOTLPPath
+	}
+	exporter, err := otlptracehttp.New(ctx, otlptracehttp.WithEndpointURL(u.String()))
+	if err != nil {
+		return nil, fmt.Errorf("OTLP exporter: %w", err)
+	}
+	return exporter, nil
+}
+
+// NewStdoutExporter creates an exporter printing spans to stdout. It is meant for local use.
+func NewStdoutExporter() (sdktrace.SpanExporter, error) {
+	exporter, err := stdouttrace.New()
+	if err != nil {
+		return nil, fmt.Errorf("stdout exporter: %w", err)
+	}
+	return exporter, nil
+}
@@ -5,10 +5,37 @@ import (
 	"time"
 )
 
-type ValidationError string
+// CodeInvalid is the code of errors added without one.
+const CodeInvalid = "invalid"
+
+type ValidationError struct {
+	// Code identifies the error for clients. Unlike Message, it never changes.
+	Code string
+	// Pointer is the JSON pointer (RFC 6901) to the offending field of the request body, e.g. /content.
+	// It is empty if the error is not tied to a single field of the body.
+	Pointer string
+	Message string
+}
 
 func (e ValidationError) Error() string {
-	return string(e)
+	return e.Message
+}
+
+// Errors returns the validation errors err consists of, looking into wrapped and joined errors.
+func Errors(err error) []ValidationError {
+	var validationErrors []ValidationError
+	switch e := err.(type) {
+	case nil:
+	case ValidationError:
+		validationErrors = append(validationErrors, e)
+	case interface{ Unwrap() []error }:
+		for _, err := range e.Unwrap() {
+			validationErrors = append(validationErrors, Errors(err)...)
+		}
+	case interface{ Unwrap() error }:
+		validationErrors = Errors(e.Unwrap())
+	}
+	return validationErrors
 }
 
 type Validator struct {
@@ -20,7 +47,11 @@ func (v *Validator) CheckIsValid() bool {
 }
 
 func (v *Validator) AddError(message string) {
-	v.Errors = append(v.Errors, ValidationError(message))
+	v.AddFieldError("", CodeInvalid, message)
+}
+
+func (v *Validator) AddFieldError(pointer, code, message string) {
+	v.Errors = append(v.Errors, ValidationError{Code: code, Pointer: pointer, Message: message})
 }
 
 func (v *Validator) Check(ok bool, message string) {
@@ -29,6 +60,13 @@ func (v *Validator) Check(ok bool, message string) {
 	}
 }
 
+// CheckField is Check for errors clients tell apart by code. pointer may be empty, see ValidationError.
+func (v *Validator) CheckField(ok bool, pointer, code, message string) {
+	if !ok {
+		v.AddFieldError(pointer, code, message)
+	}
+}
+
 func (v *Validator) GetErrors() []ValidationError {
 	return v.Errors
 }